@@ -1,5 +1,3 @@
-//go:build windows
-
 /*
 Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
 */
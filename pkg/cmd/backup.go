@@ -16,7 +16,7 @@ var backupCmd = &cobra.Command{
 	Short: "Backup the Noita save00 directory",
 	Long: `Backs up the Noita save00 directory to %USERPROFILE%\NoitaBackup or a specified destination directory
 through the environmental variable CONFIG_NOITA_DST_PATH.`,
-	PreRunE: validateCommandOptions,
+	PreRunE: validateAndRecover,
 	Run: func(cmd *cobra.Command, args []string) {
 		backup := internal.NewBackup(
 			false,
@@ -24,6 +24,7 @@ through the environmental variable CONFIG_NOITA_DST_PATH.`,
 			viper.GetInt(internal.ViperNumBackups),
 			viper.GetString(internal.ViperSourcePath),
 			viper.GetString(internal.ViperDestinationPath),
+			internal.NewProcessDetector(),
 		)
 		backup.BackupNoita()
 	},
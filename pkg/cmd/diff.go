@@ -0,0 +1,110 @@
+/*
+Package cmd
+Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/rgravlin/noitabackup/pkg/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+)
+
+var (
+	diffSummary, diffJSON bool
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <a> <b>",
+	Short: "Compare two backups or a backup and the live save",
+	Long: `Lists the files added, removed and changed going from the first save to the second together with their sizes,
+followed by a summary of the run: gold, HP, depth and biome, perks, wands, unlock flags and the number of sessions.
+Either side is a backup selected the same way as for restore or "live" for the save00 at the source path.`,
+	Args:    cobra.ExactArgs(2),
+	PreRunE: validateCommandOptions,
+	Run: func(cmd *cobra.Command, args []string) {
+		diff, err := internal.DiffSaves(
+			viper.GetString(internal.ViperDestinationPath),
+			viper.GetString(internal.ViperSourcePath),
+			args[0],
+			args[1],
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if diffJSON {
+			printJSON(diff)
+			return
+		}
+
+		fmt.Printf("%s -> %s\n", diff.From.ID, diff.To.ID)
+		if !diffSummary {
+			printFileChanges(diff.Files)
+		}
+		fmt.Printf("files: %d added, %d removed, %d changed\n",
+			diff.CountFiles(internal.FileAdded),
+			diff.CountFiles(internal.FileRemoved),
+			diff.CountFiles(internal.FileChanged),
+		)
+		printSaveSummary(diff)
+	},
+}
+
+func printFileChanges(files []internal.FileChange) {
+	for _, file := range files {
+		switch file.Change {
+		case internal.FileAdded:
+			fmt.Printf("+ %s (%s)\n", file.Path, internal.FormatSize(file.NewSize))
+		case internal.FileRemoved:
+			fmt.Printf("- %s (%s)\n", file.Path, internal.FormatSize(file.OldSize))
+		default:
+			fmt.Printf("~ %s (%s -> %s)\n", file.Path, internal.FormatSize(file.OldSize), internal.FormatSize(file.NewSize))
+		}
+	}
+}
+
+func printSaveSummary(diff *internal.Diff) {
+	from, to := diff.From.Metadata, diff.To.Metadata
+	if from != nil && to != nil {
+		fmt.Printf("gold: %d -> %d\n", from.Gold, to.Gold)
+		fmt.Printf("hp: %.0f/%.0f -> %.0f/%.0f\n", from.HP, from.MaxHP, to.HP, to.MaxHP)
+		fmt.Printf("depth: %.0f (%s) -> %.0f (%s)\n", from.Y, from.Biome, to.Y, to.Biome)
+	} else {
+		fmt.Printf("%s: %s\n", diff.From.ID, describeMetadata(from))
+		fmt.Printf("%s: %s\n", diff.To.ID, describeMetadata(to))
+	}
+
+	printChanges("perks", diff.PerksGained, diff.PerksLost)
+	printChanges("wands", diff.WandsGained, diff.WandsLost)
+	fmt.Printf("unlocks: %d -> %d\n", diff.From.Unlocks, diff.To.Unlocks)
+	printFlags(fmt.Sprintf("  %s", internal.InfoGained), diff.Unlocks.Gained)
+	printFlags(fmt.Sprintf("  %s", internal.InfoLost), diff.Unlocks.Lost)
+	fmt.Printf("sessions: %d -> %d\n", diff.From.Sessions, diff.To.Sessions)
+}
+
+func printChanges(label string, gained, lost []string) {
+	fmt.Printf("%s: +%d -%d\n", label, len(gained), len(lost))
+	for _, s := range gained {
+		fmt.Printf("  + %s\n", s)
+	}
+	for _, s := range lost {
+		fmt.Printf("  - %s\n", s)
+	}
+}
+
+func describeMetadata(metadata *internal.SaveMetadata) string {
+	if metadata == nil {
+		return internal.InfoNoMetadata
+	}
+	return metadata.String()
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().BoolVar(&diffSummary, "summary", false, "only print the summary, not every changed file")
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "print the diff as JSON")
+}
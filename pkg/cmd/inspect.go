@@ -0,0 +1,98 @@
+/*
+Package cmd
+Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rgravlin/noitabackup/pkg/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	inspectWands, inspectJSON bool
+)
+
+// inspectCmd represents the inspect command
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [backup-id]",
+	Short: "Inspect the save held by a backup",
+	Long: `Shows the player's HP, gold, position and biome recorded for a backup, the latest by default.  With --wands
+it also lists every wand with its capacity, spells per cast, cast delay, recharge, mana and spread, the spells on
+each wand and the spells in the inventory.  Backups are selected the same way as for restore.`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: validateCommandOptions,
+	Run: func(cmd *cobra.Command, args []string) {
+		id := internal.StrLatest
+		if len(args) > 0 {
+			id = args[0]
+		}
+
+		inspection, err := internal.InspectBackup(viper.GetString(internal.ViperDestinationPath), id)
+		if err != nil {
+			log.Fatalf("%s: %v", internal.ErrInspectingSave, err)
+		}
+		if !inspectWands {
+			inspection.Inventory = nil
+		}
+
+		if inspectJSON {
+			out, err := json.MarshalIndent(inspection, "", "  ")
+			if err != nil {
+				log.Fatalf("%s: %v", internal.ErrInspectingSave, err)
+			}
+			fmt.Println(string(out))
+			return
+		}
+
+		printInspection(inspection)
+	},
+}
+
+func printInspection(inspection *internal.Inspection) {
+	fmt.Printf("%s: ", inspection.ID)
+	if inspection.Metadata != nil {
+		fmt.Println(inspection.Metadata)
+	} else {
+		fmt.Println(internal.InfoNoMetadata)
+	}
+
+	if inspection.Inventory == nil {
+		return
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "WAND\tCAPACITY\tSPELLS/CAST\tCAST DELAY\tRECHARGE\tMANA\tMANA CHARGE\tSPREAD\tSHUFFLE\tSPELLS")
+	for _, wand := range inspection.Inventory.Wands {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%.2fs\t%.2fs\t%.0f/%.0f\t%.0f\t%.1f°\t%t\t%s\n",
+			wand.Name,
+			wand.Capacity,
+			wand.SpellsPerCast,
+			wand.CastDelay,
+			wand.Recharge,
+			wand.Mana,
+			wand.ManaMax,
+			wand.ManaChargeSpeed,
+			wand.Spread,
+			wand.Shuffle,
+			strings.Join(wand.Spells, ", "),
+		)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\n%s: %s\n", internal.InfoInventorySpells, strings.Join(inspection.Inventory.Spells, ", "))
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+	inspectCmd.Flags().BoolVar(&inspectWands, "wands", false, "list the wands and spells of the player")
+	inspectCmd.Flags().BoolVar(&inspectJSON, "json", false, "print the inspection as JSON")
+}
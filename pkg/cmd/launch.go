@@ -7,22 +7,57 @@ package cmd
 import (
 	"github.com/rgravlin/noitabackup/pkg/internal"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"log"
+	"time"
+)
+
+var (
+	launchBackupOnExit  bool
+	launchRestoreBefore string
+	launchStartTimeout  time.Duration
 )
 
 // launchCmd represents the launch command
 var launchCmd = &cobra.Command{
 	Use:   "launch",
 	Short: "Launch the Noita game from Steam",
-	Long:  `Launches the Noita Steam game`,
+	Long: `Launches the Noita Steam game.  With --backup-on-exit it waits for the game to start, tracks it until it
+exits and then backs up the save00 directory.  With --restore-before a backup, selected the same way as for restore,
+is restored before the game is launched.`,
+	PreRunE: validateAndRecover,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := internal.LaunchNoita(false)
-		if err != nil {
-			log.Printf("%s: %v", internal.ErrLaunchingNoita, err)
+		detector := internal.NewProcessDetector()
+		if !launchBackupOnExit && launchRestoreBefore == "" {
+			err := internal.LaunchNoita(false, detector)
+			if err != nil {
+				log.Printf("%s: %v", internal.ErrLaunchingNoita, err)
+			}
+			return
+		}
+
+		session := internal.NewSession(
+			internal.NewBackup(
+				false,
+				false,
+				viper.GetInt(internal.ViperNumBackups),
+				viper.GetString(internal.ViperSourcePath),
+				viper.GetString(internal.ViperDestinationPath),
+				detector,
+			),
+			launchRestoreBefore,
+			launchBackupOnExit,
+		)
+		session.StartTimeout = launchStartTimeout
+		if err := session.Run(); err != nil {
+			log.Fatalf("%s: %v", internal.ErrSessionFailed, err)
 		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(launchCmd)
+	launchCmd.Flags().BoolVar(&launchBackupOnExit, "backup-on-exit", false, "wait for Noita to exit and back up afterwards")
+	launchCmd.Flags().StringVar(&launchRestoreBefore, "restore-before", "", "restore this backup (id, latest~N or date prefix) before launching")
+	launchCmd.Flags().DurationVar(&launchStartTimeout, "start-timeout", internal.DefaultSessionStartTimeout, "how long to wait for Noita to start with --backup-on-exit")
 }
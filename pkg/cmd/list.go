@@ -0,0 +1,194 @@
+/*
+Package cmd
+Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/rgravlin/noitabackup/pkg/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	listJSON, listCSV, listLong bool
+	listSince, listBefore       string
+	listLimit                   int
+	listFilters                 []string
+)
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List backups",
+	Long: `Lists backups newest first with their id, age, logical and disk size, file count, format and pin state along
+with the HP, gold and biome of the player when the backup was taken.  The logical size adds up the files a backup
+holds, the disk size is what the backup takes on disk on top of the older backups, counting files hard linked and
+objects shared between backups once for the oldest backup holding them.  Use --since and --before to
+restrict the listing to a time range, given either as a duration before now such as 24h or as a date such as
+2024-06-12, and --limit to show only the newest backups.  --long adds the perks, orbs and new game+ cycle of the
+run and --filter, which can be repeated, only lists backups whose save matches perk=EXTRA_PERK, biome="Snowy Depths",
+orbs=3 or ng=1.`,
+	PreRunE: validateCommandOptions,
+	Run: func(cmd *cobra.Command, args []string) {
+		if listJSON && listCSV {
+			log.Fatalf("%s: --json and --csv are mutually exclusive", internal.ErrListFailed)
+		}
+
+		filter := internal.ListFilter{Limit: listLimit}
+		var err error
+		if listSince != "" {
+			if filter.Since, err = internal.ParseTimeFilter(listSince); err != nil {
+				log.Fatalf("%s: %v", internal.ErrListFailed, err)
+			}
+		}
+		if listBefore != "" {
+			if filter.Before, err = internal.ParseTimeFilter(listBefore); err != nil {
+				log.Fatalf("%s: %v", internal.ErrListFailed, err)
+			}
+		}
+
+		for _, f := range listFilters {
+			metadataFilter, err := internal.ParseMetadataFilter(f)
+			if err != nil {
+				log.Fatalf("%s: %v", internal.ErrListFailed, err)
+			}
+			filter.Metadata = append(filter.Metadata, metadataFilter)
+		}
+
+		infos, err := internal.ListBackups(viper.GetString(internal.ViperDestinationPath), filter)
+		if err != nil {
+			log.Fatalf("%s: %v", internal.ErrListFailed, err)
+		}
+
+		switch {
+		case listJSON:
+			err = printListJSON(infos)
+		case listCSV:
+			err = printListCSV(infos)
+		default:
+			err = printListTable(infos)
+		}
+		if err != nil {
+			log.Fatalf("%s: %v", internal.ErrListFailed, err)
+		}
+
+		// interrupted backups are not listed, but should not go unnoticed either
+		if quarantined, err := internal.QuarantinedBackups(viper.GetString(internal.ViperDestinationPath)); err == nil && len(quarantined) > 0 {
+			log.Printf(internal.InfoQuarantineHolds, len(quarantined), filepath.Dir(quarantined[0]))
+		}
+	},
+}
+
+func printListJSON(infos []internal.BackupInfo) error {
+	if infos == nil {
+		infos = []internal.BackupInfo{}
+	}
+	out, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func printListCSV(infos []internal.BackupInfo) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{"id", "timestamp", "format", "logical_size", "disk_size", "files", "pinned", "version", "duration", "path", "hp", "max_hp", "gold", "x", "y", "biome", "perks", "orbs", "new_game_plus"}); err != nil {
+		return err
+	}
+	for _, info := range infos {
+		metadata := []string{"", "", "", "", "", "", "", "", ""}
+		if m := info.Metadata; m != nil {
+			metadata = []string{
+				strconv.FormatFloat(m.HP, 'f', -1, 64),
+				strconv.FormatFloat(m.MaxHP, 'f', -1, 64),
+				strconv.Itoa(m.Gold),
+				strconv.FormatFloat(m.X, 'f', -1, 64),
+				strconv.FormatFloat(m.Y, 'f', -1, 64),
+				m.Biome,
+				strings.Join(m.Perks, ";"),
+				strconv.Itoa(m.Orbs),
+				strconv.Itoa(m.NewGamePlus),
+			}
+		}
+		if err := w.Write(append([]string{
+			info.ID,
+			info.Timestamp.Format(time.RFC3339),
+			info.Format,
+			strconv.FormatInt(info.LogicalSize, 10),
+			strconv.FormatInt(info.DiskSize, 10),
+			strconv.Itoa(info.Files),
+			strconv.FormatBool(info.Pinned),
+			info.Version,
+			info.Duration,
+			info.Path,
+		}, metadata...)); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func printListTable(infos []internal.BackupInfo) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "ID\tAGE\tLOGICAL SIZE\tDISK SIZE\tFILES\tFORMAT\tPINNED\tHP\tGOLD\tBIOME"
+	if listLong {
+		header += "\tORBS\tNG+\tPERKS"
+	}
+	_, _ = fmt.Fprintln(w, header)
+	for _, info := range infos {
+		pinned := ""
+		if info.Pinned {
+			pinned = "yes"
+		}
+		hp, gold, biome, orbs, ng, perks := "", "", "", "", "", ""
+		if m := info.Metadata; m != nil {
+			hp = fmt.Sprintf("%.0f/%.0f", m.HP, m.MaxHP)
+			gold = strconv.Itoa(m.Gold)
+			biome = m.Biome
+			orbs = strconv.Itoa(m.Orbs)
+			ng = strconv.Itoa(m.NewGamePlus)
+			perks = strings.Join(m.Perks, ", ")
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s",
+			info.ID,
+			internal.FormatAge(info.Timestamp),
+			internal.FormatSize(info.LogicalSize),
+			internal.FormatSize(info.DiskSize),
+			info.Files,
+			info.Format,
+			pinned,
+			hp,
+			gold,
+			biome,
+		)
+		if listLong {
+			_, _ = fmt.Fprintf(w, "\t%s\t%s\t%s", orbs, ng, perks)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().BoolVar(&listJSON, "json", false, "print the backups as JSON")
+	listCmd.Flags().BoolVar(&listCSV, "csv", false, "print the backups as CSV")
+	listCmd.Flags().StringVar(&listSince, "since", "", "only list backups taken at or after this time")
+	listCmd.Flags().StringVar(&listBefore, "before", "", "only list backups taken before this time")
+	listCmd.Flags().BoolVar(&listLong, "long", false, "also show the perks, orbs and new game+ cycle of each backup")
+	listCmd.Flags().StringArrayVar(&listFilters, "filter", nil, "only list backups matching perk=ID, biome=NAME, orbs=N or ng=N, can be repeated")
+	listCmd.Flags().IntVar(&listLimit, "limit", 0, "only list the newest N backups, 0 lists all")
+}
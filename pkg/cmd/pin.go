@@ -0,0 +1,33 @@
+/*
+Package cmd
+Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
+*/
+package cmd
+
+import (
+	"github.com/rgravlin/noitabackup/pkg/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+)
+
+// pinCmd represents the pin command
+var pinCmd = &cobra.Command{
+	Use:   "pin <backup-id>",
+	Short: "Protect a backup from rotation",
+	Long: `Pins a backup so it is never removed when rotating or pruning backups and does not count against
+num-backups.  The pin is stored as a .pin file next to the backup.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: validateCommandOptions,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := internal.PinBackup(viper.GetString(internal.ViperDestinationPath), args[0])
+		if err != nil {
+			log.Fatalf("%s: %v", internal.ErrPinningBackup, err)
+		}
+		log.Printf("%s: %s", internal.InfoPinned, id)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pinCmd)
+}
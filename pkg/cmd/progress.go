@@ -0,0 +1,64 @@
+/*
+Package cmd
+Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rgravlin/noitabackup/pkg/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+)
+
+var (
+	progressJSON bool
+)
+
+// progressCmd represents the progress command
+var progressCmd = &cobra.Command{
+	Use:   "progress [backup-id]",
+	Short: "Count the unlocks recorded in a backup",
+	Long: `Counts the unlocked spells, picked perks, secrets and other progress recorded as files in
+save00/persistent/flags of a backup, the latest by default.  Backups are selected the same way as for restore, use
+progress diff to compare the unlocks of two backups.`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: validateCommandOptions,
+	Run: func(cmd *cobra.Command, args []string) {
+		id := internal.StrLatest
+		if len(args) > 0 {
+			id = args[0]
+		}
+
+		progress, err := internal.BackupProgress(viper.GetString(internal.ViperDestinationPath), id)
+		if err != nil {
+			log.Fatalf("%s: %v", internal.ErrReadingProgress, err)
+		}
+
+		if progressJSON {
+			printJSON(progress)
+			return
+		}
+
+		fmt.Printf("%s: %d %s\n", progress.ID, progress.Total(), internal.InfoUnlocks)
+		for _, category := range progress.Categories() {
+			fmt.Printf("  %-10s %d\n", category, len(progress.Flags[category]))
+		}
+	},
+}
+
+// printJSON prints v as indented JSON.
+func printJSON(v any) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(out))
+}
+
+func init() {
+	rootCmd.AddCommand(progressCmd)
+	progressCmd.PersistentFlags().BoolVar(&progressJSON, "json", false, "print the unlocks as JSON")
+}
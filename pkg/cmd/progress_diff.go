@@ -0,0 +1,59 @@
+/*
+Package cmd
+Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/rgravlin/noitabackup/pkg/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+	"sort"
+	"strings"
+)
+
+// progressDiffCmd represents the progress diff command
+var progressDiffCmd = &cobra.Command{
+	Use:   "diff <backup-a> <backup-b>",
+	Short: "Show the unlocks gained or lost between two backups",
+	Long: `Compares the unlock flags in save00/persistent/flags of two backups and lists the flags gained and lost going
+from the first backup to the second, grouped by category.  Backups are selected the same way as for restore.`,
+	Args:    cobra.ExactArgs(2),
+	PreRunE: validateCommandOptions,
+	Run: func(cmd *cobra.Command, args []string) {
+		diff, err := internal.DiffProgress(viper.GetString(internal.ViperDestinationPath), args[0], args[1])
+		if err != nil {
+			log.Fatalf("%s: %v", internal.ErrReadingProgress, err)
+		}
+
+		if progressJSON {
+			printJSON(diff)
+			return
+		}
+
+		fmt.Printf("%s -> %s\n", diff.From, diff.To)
+		printFlags(internal.InfoGained, diff.Gained)
+		printFlags(internal.InfoLost, diff.Lost)
+	},
+}
+
+func printFlags(label string, flags map[string][]string) {
+	var categories []string
+	total := 0
+	for category, names := range flags {
+		categories = append(categories, category)
+		total += len(names)
+	}
+	sort.Strings(categories)
+
+	fmt.Printf("%s %d\n", label, total)
+	for _, category := range categories {
+		fmt.Printf("  %s: %s\n", category, strings.Join(flags[category], ", "))
+	}
+}
+
+func init() {
+	progressCmd.AddCommand(progressDiffCmd)
+}
@@ -0,0 +1,67 @@
+/*
+Package cmd
+Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/rgravlin/noitabackup/pkg/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+	"strings"
+)
+
+var (
+	pruneDryRun bool
+)
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove backups according to the retention policy",
+	Long: `Applies the grandfather-father-son retention policy configured through keep-last, keep-hourly, keep-daily,
+keep-weekly and keep-monthly and removes every backup that no rule keeps.  Without any of those settings the newest
+num-backups backups are kept.  Interrupted backups quarantined more than a week ago are removed as well.  Use
+--dry-run to explain why each backup would be kept or removed.`,
+	PreRunE: validateCommandOptions,
+	Run: func(cmd *cobra.Command, args []string) {
+		backup := internal.NewBackup(
+			false,
+			false,
+			viper.GetInt(internal.ViperNumBackups),
+			viper.GetString(internal.ViperSourcePath),
+			viper.GetString(internal.ViperDestinationPath),
+			internal.NewProcessDetector(),
+		)
+
+		decisions, err := backup.Prune(pruneDryRun)
+		if err != nil {
+			log.Fatalf("%s: %v", internal.ErrPruneFailed, err)
+		}
+
+		if pruneDryRun {
+			for _, decision := range decisions {
+				action := internal.InfoRemove
+				if decision.Keep {
+					action = internal.InfoKeep
+				}
+				fmt.Printf("%s  %-6s  %s\n", decision.ID, action, strings.Join(decision.Reasons, ", "))
+			}
+		}
+
+		removed, err := internal.CleanQuarantine(viper.GetString(internal.ViperDestinationPath), pruneDryRun)
+		if err != nil {
+			log.Fatalf("%s: %v", internal.ErrQuarantiningStaging, err)
+		}
+		for _, path := range removed {
+			log.Printf("%s: %s", internal.InfoPurgedBackup, path)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "only explain which backups would be kept or removed")
+}
@@ -5,34 +5,89 @@ Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
 package cmd
 
 import (
+	"fmt"
 	"github.com/rgravlin/noitabackup/pkg/internal"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+var (
+	restoreTarget                                   string
+	restoreDryRun, restoreForce, restoreBackupFirst bool
+)
+
 // restoreCmd represents the restore command
 var restoreCmd = &cobra.Command{
-	Use:   "restore",
-	Short: "Restore the latest backed up Noita save",
-	Long: `Restores the latest backed up Noita save to the save00 directory or a specified source directory through the
-environmental variable CONFIG_NOITA_SRC_PATH.  Preserves your current save by deleting save00.bak and renaming save00
-to save00.bak.  It then restores the latest save file to the save00 directory.`,
-	PreRunE: validateCommandOptions,
+	Use:   "restore [backup-id]",
+	Short: "Restore a backed up Noita save, the latest by default",
+	Long: `Restores a backed up Noita save to the save00 directory or a specified source directory through the
+environmental variable CONFIG_NOITA_SRC_PATH.  Preserves your current save by renaming save00 to save00.bak.1 after
+shifting the older generations up to save00.bak.N, set by --restore-generations.  It then restores the selected save
+file to the save00 directory.  Use undo-restore to bring the replaced save back.
+
+The backup is selected by an exact timestamp such as 2024-06-12-17-49-18, by latest or latest~N for the Nth backup
+before the latest, or by a timestamp prefix such as 2024-06-12 for the latest backup of that day.
+
+With --target the backup is extracted into that directory instead.  save00 and its generations are left untouched and
+Noita may keep running.  The target has to be empty or not exist yet and may not lie inside save00, its generations
+or the backup directory.
+
+With --dry-run every check of a restore is run and the files that would be replaced, added and removed compared
+with the current save00, or the target, are listed along with the bytes to copy.  Nothing is changed.
+
+A restore is refused when save00 was changed after the newest backup or holds more sessions than it, as that progress
+would only survive in save00.bak.1.  Use --backup-first to back up save00 before restoring or --force to restore
+anyway.`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: validateAndRecover,
 	Run: func(cmd *cobra.Command, args []string) {
+		id := internal.StrLatest
+		if len(args) > 0 {
+			id = args[0]
+		}
+
 		restore := internal.NewRestore(
-			"latest",
+			id,
 			internal.NewBackup(
 				false,
 				viper.GetBool(internal.ViperAutoLaunch),
 				viper.GetInt(internal.ViperNumBackups),
 				viper.GetString(internal.ViperSourcePath),
 				viper.GetString(internal.ViperDestinationPath),
+				internal.NewProcessDetector(),
 			),
 		)
+		restore.Target = restoreTarget
+		restore.DryRun = restoreDryRun
+		restore.Force = restoreForce
+		restore.BackupFirst = restoreBackupFirst
 		restore.RestoreNoita()
+
+		if restore.Preview != nil {
+			printRestorePreview(restore.Preview)
+		}
 	},
 }
 
+func printRestorePreview(preview *internal.RestorePreview) {
+	fmt.Printf("%s: %s\n", preview.Backup, describeMetadata(preview.Metadata))
+	printFileChanges(preview.Files)
+	if preview.Unsaved != nil {
+		fmt.Printf("%s: %s\n", internal.ErrUnsavedProgress, preview.Unsaved)
+	}
+	fmt.Printf("%d replaced, %d added, %d removed, %s to copy into %s\n",
+		preview.CountFiles(internal.FileChanged),
+		preview.CountFiles(internal.FileAdded),
+		preview.CountFiles(internal.FileRemoved),
+		internal.FormatSize(preview.Bytes),
+		preview.Destination,
+	)
+}
+
 func init() {
 	rootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "show what the restore would change without changing anything")
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "restore even when save00 has progress newer than the newest backup")
+	restoreCmd.Flags().BoolVar(&restoreBackupFirst, "backup-first", false, "back up save00 first when it has progress newer than the newest backup")
+	restoreCmd.Flags().StringVar(&restoreTarget, "target", "", "extract the backup into this directory instead of save00")
 }
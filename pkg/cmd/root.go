@@ -18,17 +18,19 @@ import (
 
 const (
 	appName                   = "Noita Backup and Restore"
-	ConfigDefaultSteamDir     = "C:\\Program Files (x86)\\Steam\\steam.exe"
 	ConfigMaxNumBackupsToKeep = 64
 	ConfigDefaultNumBackups   = 16
 	ConfigMaxNumWorkers       = 32
 	ConfigDefaultNumWorkers   = 4
+	ConfigDefaultFormat       = internal.FormatDir
+	ConfigDefaultGenerations  = 3
 )
 
 var (
-	cfgFile, sourcePath, destinationPath, steamPath string
-	numBackupsToKeep, numCopyWorkers                int
-	autoLaunch                                      bool
+	cfgFile, sourcePath, destinationPath, steamPath, format  string
+	numBackupsToKeep, numCopyWorkers, generations            int
+	keepLast, keepHourly, keepDaily, keepWeekly, keepMonthly int
+	autoLaunch, incremental                                  bool
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -46,7 +48,7 @@ backing up, restoring, and restarting Noita.  Includes both a GUI and command li
 				app.MaxSize(unit.Dp(internal.DefaultWidth), unit.Dp(internal.DefaultMinHeight)),
 				app.MinSize(unit.Dp(internal.DefaultWidth), unit.Dp(internal.DefaultMinHeight)),
 			)
-			ui := internal.NewUI(viper.GetBool(internal.ViperAutoLaunch))
+			ui := internal.NewUI(viper.GetBool(internal.ViperAutoLaunch), internal.NewProcessDetector())
 			err := ui.Run(window)
 			if err != nil {
 				log.Fatal(err)
@@ -75,10 +77,18 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.noitabackup.yaml)")
 	rootCmd.PersistentFlags().StringVar(&sourcePath, internal.ViperSourcePath, internal.GetDefaultSourcePath(), "source Noita save00 path")
 	rootCmd.PersistentFlags().StringVar(&destinationPath, internal.ViperDestinationPath, internal.GetDefaultDestinationPath(), "destination backup path")
-	rootCmd.PersistentFlags().StringVar(&steamPath, internal.ViperSteamPath, ConfigDefaultSteamDir, "path for your Steam executable")
+	rootCmd.PersistentFlags().StringVar(&steamPath, internal.ViperSteamPath, internal.GetDefaultSteamPath(), "path for your Steam executable")
 	rootCmd.PersistentFlags().IntVar(&numBackupsToKeep, internal.ViperNumBackups, ConfigDefaultNumBackups, "maximum number of backups to keep")
 	rootCmd.PersistentFlags().IntVar(&numCopyWorkers, internal.ViperNumWorkers, ConfigDefaultNumWorkers, "total number of go routine workers (advanced usage)")
 	rootCmd.PersistentFlags().BoolVar(&autoLaunch, internal.ViperAutoLaunch, false, "auto-launch Noita after backup/restore operation")
+	rootCmd.PersistentFlags().IntVar(&keepLast, internal.ViperKeepLast, 0, "retention: keep the newest n backups")
+	rootCmd.PersistentFlags().IntVar(&keepHourly, internal.ViperKeepHourly, 0, "retention: keep the newest backup of the last n hours")
+	rootCmd.PersistentFlags().IntVar(&keepDaily, internal.ViperKeepDaily, 0, "retention: keep the newest backup of the last n days")
+	rootCmd.PersistentFlags().IntVar(&keepWeekly, internal.ViperKeepWeekly, 0, "retention: keep the newest backup of the last n weeks")
+	rootCmd.PersistentFlags().IntVar(&keepMonthly, internal.ViperKeepMonthly, 0, "retention: keep the newest backup of the last n months")
+	rootCmd.PersistentFlags().BoolVar(&incremental, internal.ViperIncremental, false, "hardlink files unchanged since the newest dir backup instead of copying them")
+	rootCmd.PersistentFlags().StringVar(&format, internal.ViperBackupFormat, ConfigDefaultFormat, "backup format, one of dir, zip or repo")
+	rootCmd.PersistentFlags().IntVar(&generations, internal.ViperGenerations, ConfigDefaultGenerations, "number of save00.bak.N generations kept by restore")
 
 	commands := []string{
 		internal.ViperSourcePath,
@@ -87,6 +97,14 @@ func init() {
 		internal.ViperNumWorkers,
 		internal.ViperAutoLaunch,
 		internal.ViperSteamPath,
+		internal.ViperBackupFormat,
+		internal.ViperIncremental,
+		internal.ViperKeepLast,
+		internal.ViperKeepHourly,
+		internal.ViperKeepDaily,
+		internal.ViperKeepWeekly,
+		internal.ViperKeepMonthly,
+		internal.ViperGenerations,
 	}
 
 	for _, cmd := range commands {
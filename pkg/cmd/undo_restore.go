@@ -0,0 +1,37 @@
+/*
+Package cmd
+Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
+*/
+package cmd
+
+import (
+	"github.com/rgravlin/noitabackup/pkg/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+)
+
+// undoRestoreCmd represents the undo-restore command
+var undoRestoreCmd = &cobra.Command{
+	Use:   "undo-restore",
+	Short: "Swap save00 with the save replaced by the last restore",
+	Long: `Swaps save00 with save00.bak.1, the save that was replaced by the last restore.  The restored save takes the
+place of save00.bak.1, so running undo-restore again swaps them back.  Older generations are left untouched.
+A restore interrupted by a crash has to be rolled forward or back first.`,
+	Args:    cobra.NoArgs,
+	PreRunE: validateAndRecover,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.UndoRestore(
+			internal.NewLogRing(16),
+			viper.GetString(internal.ViperSourcePath),
+			viper.GetString(internal.ViperDestinationPath),
+			internal.NewProcessDetector(),
+		); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoRestoreCmd)
+}
@@ -0,0 +1,32 @@
+/*
+Package cmd
+Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
+*/
+package cmd
+
+import (
+	"github.com/rgravlin/noitabackup/pkg/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+)
+
+// unpinCmd represents the unpin command
+var unpinCmd = &cobra.Command{
+	Use:     "unpin <backup-id>",
+	Short:   "Allow a pinned backup to rotate again",
+	Long:    `Removes the pin of a backup so it is rotated and pruned like any other backup.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: validateCommandOptions,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := internal.UnpinBackup(viper.GetString(internal.ViperDestinationPath), args[0])
+		if err != nil {
+			log.Fatalf("%s: %v", internal.ErrPinningBackup, err)
+		}
+		log.Printf("%s: %s", internal.InfoUnpinned, id)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(unpinCmd)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/viper"
 	"log"
 	"os"
+	"slices"
 )
 
 func validateCommandOptions(cmd *cobra.Command, args []string) error {
@@ -24,16 +25,47 @@ func validateCommandOptions(cmd *cobra.Command, args []string) error {
 		uiErr = fmt.Sprintf("%s: %d", internal.ErrNumWorkers, numWorkers)
 	}
 
-	if path, err := internal.GetSourcePath(viper.GetString(internal.ViperSourcePath)); err != nil {
-		uiErr = fmt.Sprintf("%v", err)
+	if generations := viper.GetInt(internal.ViperGenerations); generations <= 0 {
+		uiErr = fmt.Sprintf("%s: %d", internal.ErrGenerations, generations)
+	}
+
+	for _, keep := range []string{
+		internal.ViperKeepLast,
+		internal.ViperKeepHourly,
+		internal.ViperKeepDaily,
+		internal.ViperKeepWeekly,
+		internal.ViperKeepMonthly,
+	} {
+		if viper.GetInt(keep) < 0 {
+			uiErr = fmt.Sprintf("%s: %s", internal.ErrKeepNegative, keep)
+		}
+	}
+
+	backupFormat := viper.GetString(internal.ViperBackupFormat)
+	if !slices.Contains(internal.BackupFormats, backupFormat) {
+		uiErr = fmt.Sprintf("%s: %s", internal.ErrInvalidFormat, backupFormat)
+	}
+
+	dstPath, dstErr := internal.GetDestinationPath(viper.GetString(internal.ViperDestinationPath))
+	if dstErr != nil {
+		uiErr = fmt.Sprintf("%v", dstErr)
 	} else {
-		viper.Set(internal.ViperSourcePath, path)
+		viper.Set(internal.ViperDestinationPath, dstPath)
+		// move aside backups interrupted by a crash before anything lists or restores them
+		internal.CleanStaging(internal.NewLogRing(16), dstPath)
 	}
 
-	if path, err := internal.GetDestinationPath(viper.GetString(internal.ViperDestinationPath)); err != nil {
-		uiErr = fmt.Sprintf("%v", err)
+	if path, err := internal.GetSourcePath(viper.GetString(internal.ViperSourcePath)); err != nil {
+		// a crash in the middle of a restore leaves save00 missing, which is recovered
+		// from rather than reported
+		if journal, _ := internal.ReadRestoreJournal(dstPath); dstErr == nil && journal.Incomplete() && journal.Source == path {
+			log.Print(err)
+			viper.Set(internal.ViperSourcePath, path)
+		} else {
+			uiErr = fmt.Sprintf("%v", err)
+		}
 	} else {
-		viper.Set(internal.ViperDestinationPath, path)
+		viper.Set(internal.ViperSourcePath, path)
 	}
 
 	if path, err := internal.GetSteamPath(viper.GetString(internal.ViperSteamPath)); err != nil {
@@ -49,6 +81,53 @@ func validateCommandOptions(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// validateAndRecover validates the options of a command that changes save00 and first
+// offers to recover a restore into save00 that was interrupted by a crash.
+func validateAndRecover(cmd *cobra.Command, args []string) error {
+	if err := validateCommandOptions(cmd, args); err != nil {
+		return err
+	}
+
+	recoverRestore(viper.GetString(internal.ViperDestinationPath))
+	return nil
+}
+
+// recoverRestore asks whether to roll an interrupted restore into save00 forward or back.
+// Without a terminal to ask on the restore is left alone.
+func recoverRestore(path string) {
+	journal, err := internal.ReadRestoreJournal(path)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if !journal.Incomplete() {
+		return
+	}
+
+	log.Print(journal)
+	if process, running := internal.NewProcessDetector().NoitaProcess(); running {
+		log.Printf("%s: %s %s", internal.InfoJournalKept, internal.ErrNoitaRunning, process)
+		return
+	}
+	if !isTerminal(os.Stdin) {
+		log.Printf("%s: %s", internal.InfoJournalKept, internal.ErrNoTerminal)
+		return
+	}
+
+	logRing := internal.NewLogRing(16)
+	switch {
+	case confirm(internal.InfoRollForward):
+		err = journal.RollForward(logRing, path)
+	case confirm(internal.InfoRollBack):
+		err = journal.RollBack(logRing, path)
+	default:
+		log.Print(internal.InfoJournalKept)
+	}
+	if err != nil {
+		log.Print(err)
+	}
+}
+
 func RunErrorUI(error string) {
 	go func() {
 		window := new(app.Window)
@@ -0,0 +1,95 @@
+/*
+Package cmd
+Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rgravlin/noitabackup/pkg/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+)
+
+var (
+	verifyAll, verifyJSON bool
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify [backup-id]",
+	Short: "Verify the integrity of backups",
+	Long: `Verifies a backup, the latest by default, by re-reading it and comparing it against the manifest recorded
+when the backup was taken, or against the file list of zip backups taken before manifests were recorded.  Reports
+missing, extra, truncated and hash mismatched files and exits non-zero when any problem is found.  Backups that
+recorded neither are reported as unverifiable.`,
+	Args:    cobra.MatchAll(cobra.MaximumNArgs(1), verifyArgs),
+	PreRunE: validateCommandOptions,
+	Run: func(cmd *cobra.Command, args []string) {
+		id := internal.StrLatest
+		if len(args) > 0 {
+			id = args[0]
+		}
+
+		results, err := internal.VerifyBackups(
+			viper.GetString(internal.ViperDestinationPath),
+			id,
+			verifyAll,
+			viper.GetInt(internal.ViperNumWorkers),
+		)
+		if err != nil {
+			log.Fatalf("%s: %v", internal.ErrVerifyFailed, err)
+		}
+
+		ok := true
+		for _, result := range results {
+			ok = ok && result.OK()
+		}
+
+		if verifyJSON {
+			out, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				log.Fatalf("%s: %v", internal.ErrVerifyFailed, err)
+			}
+			fmt.Println(string(out))
+		} else {
+			for _, result := range results {
+				if result.Unverifiable {
+					fmt.Printf("%s: %s\n", result.ID, internal.InfoVerifyUnverified)
+					continue
+				}
+				if result.OK() {
+					fmt.Printf("%s: %s\n", result.ID, internal.InfoVerifyOK)
+					continue
+				}
+				fmt.Printf("%s: %s\n", result.ID, internal.ErrVerifyFailed)
+				for _, problem := range result.Problems() {
+					fmt.Printf("  %s\n", problem)
+				}
+			}
+		}
+
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
+// verifyArgs rejects a backup id together with --all
+func verifyArgs(cmd *cobra.Command, args []string) error {
+	if verifyAll && len(args) > 0 {
+		return errors.New(internal.ErrVerifyAllWithID)
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().BoolVar(&verifyAll, "all", false, "verify every backup")
+	verifyCmd.Flags().BoolVar(&verifyJSON, "json", false, "print the results as JSON")
+}
@@ -0,0 +1,102 @@
+/*
+Package cmd
+Copyright © 2024 Ryan Gravlin ryan.gravlin@gmail.com
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/rgravlin/noitabackup/pkg/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	watchPollInterval, watchDebounce, watchMinInterval time.Duration
+	watchSkipUnchanged                                 bool
+	watchSaveScum                                      string
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Back up automatically whenever Noita exits",
+	Long: `Keeps running and polls for noita.exe, backing up the save00 directory every time the game exits.  An exit
+only counts once Noita stayed closed for the debounce period, backups are at least min-interval apart and are skipped
+when nothing in save00 changed since the latest backup.  Stop watching with Ctrl+C.
+
+With --save-scum auto a run that ended in death restores the newest backup taken before that run instead of being
+backed up, --save-scum prompt asks first.  Every such restore is recorded in savescum.log in the backup directory.`,
+	PreRunE: validateAndRecover,
+	Run: func(cmd *cobra.Command, args []string) {
+		if watchPollInterval <= 0 {
+			log.Fatalf("%s: %s", internal.ErrInvalidInterval, watchPollInterval)
+		}
+		if !slices.Contains(internal.SaveScumModes, watchSaveScum) {
+			log.Fatalf("%s: %s", internal.ErrInvalidSaveScum, watchSaveScum)
+		}
+
+		detector := internal.NewProcessDetector()
+		watcher := internal.NewWatcher(
+			detector,
+			func() *internal.Backup {
+				return internal.NewBackup(
+					false,
+					false,
+					viper.GetInt(internal.ViperNumBackups),
+					viper.GetString(internal.ViperSourcePath),
+					viper.GetString(internal.ViperDestinationPath),
+					detector,
+				)
+			},
+			watchPollInterval,
+			watchDebounce,
+			watchMinInterval,
+			watchSkipUnchanged,
+		)
+		watcher.SaveScum = watchSaveScum
+		watcher.Confirm = confirm
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if err := watcher.Run(ctx); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// confirm asks a yes or no question on the terminal, anything but yes is a no.  The
+// question goes to stderr so it never ends up in output meant for other programs.
+func confirm(question string) bool {
+	_, _ = fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().DurationVar(&watchPollInterval, "poll-interval", 2*time.Second, "how often to check whether Noita is running")
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", 10*time.Second, "how long Noita must stay closed before backing up")
+	watchCmd.Flags().DurationVar(&watchMinInterval, "min-interval", 5*time.Minute, "minimum time between two backups")
+	watchCmd.Flags().StringVar(&watchSaveScum, "save-scum", internal.SaveScumOff, "after a death restore the newest earlier backup, one of off, auto or prompt")
+	watchCmd.Flags().BoolVar(&watchSkipUnchanged, "skip-unchanged", true, "skip the backup when save00 did not change since the latest backup")
+}
@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	FormatDir    = "dir"
+	FormatZip    = "zip"
+	zipExtension = ".zip"
+)
+
+// BackupFormats lists every supported backup format.
+var BackupFormats = []string{FormatDir, FormatZip, FormatRepo}
+
+// zipDirectory recursively writes the contents of src into a single zip archive at dst
+// and finishes the archive with a manifest of everything it contains.
+func zipDirectory(src, dst string, dirCounter, fileCounter *int, newManifest func([]ManifestEntry) *Manifest) (err error) {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer func(out *os.File) {
+		// an archive that failed to close may be incomplete and must not be renamed into
+		// place, so the error is returned rather than logged
+		if closeErr := out.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("%s: %v", ErrClosingFile, closeErr)
+		}
+	}(out)
+
+	var entries []ManifestEntry
+	zw := zip.NewWriter(out)
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+
+		entry := ManifestEntry{
+			Path:    header.Name,
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		}
+
+		if d.IsDir() {
+			header.Name += "/"
+			if _, err := zw.CreateHeader(header); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			*dirCounter += 1
+			return nil
+		}
+
+		header.Method = zip.Deflate
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		if err := copyToWriter(path, io.MultiWriter(w, h)); err != nil {
+			return err
+		}
+		entry.Size = info.Size()
+		entry.SHA256 = hex.EncodeToString(h.Sum(nil))
+		entries = append(entries, entry)
+		*fileCounter += 1
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(newManifest(entries), "", "  ")
+	if err != nil {
+		return err
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: manifestFileName, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// extractZip restores every entry of the zip archive at src below the dst directory,
+// leaving out the top level entry named exclude.
+func extractZip(src, dst, exclude string, dirCounter, fileCounter *int) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer func(zr *zip.ReadCloser) {
+		err := zr.Close()
+		if err != nil {
+			log.Printf("%s: %v", ErrClosingFile, err)
+		}
+	}(zr)
+
+	for _, f := range zr.File {
+		name := strings.TrimSuffix(f.Name, "/")
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("%s: %s", ErrInvalidArchiveEntry, f.Name)
+		}
+		if exclude != "" && name == exclude {
+			continue
+		}
+		path := filepath.Join(dst, filepath.FromSlash(name))
+
+		if f.FileInfo().IsDir() {
+			if err := createIfNotExists(path, Mode0755); err != nil {
+				return err
+			}
+			*dirCounter += 1
+			continue
+		}
+
+		if err := createIfNotExists(filepath.Dir(path), Mode0755); err != nil {
+			return err
+		}
+		if err := extractZipFile(f, path); err != nil {
+			return err
+		}
+		if err := os.Chtimes(path, f.Modified, f.Modified); err != nil {
+			return err
+		}
+		*fileCounter += 1
+	}
+
+	return nil
+}
+
+func extractZipFile(f *zip.File, dst string) error {
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func(in io.ReadCloser) {
+		err := in.Close()
+		if err != nil {
+			log.Printf("%s: %v", ErrClosingFile, err)
+		}
+	}(in)
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, f.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func(out *os.File) {
+		err := out.Close()
+		if err != nil {
+			log.Printf("%s: %v", ErrClosingFile, err)
+		}
+	}(out)
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func copyToWriter(src string, w io.Writer) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func(in *os.File) {
+		err := in.Close()
+		if err != nil {
+			log.Printf("%s: %v", ErrClosingFile, err)
+		}
+	}(in)
+
+	_, err = io.Copy(w, in)
+	return err
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -14,8 +15,13 @@ const (
 	ConfigMaxNumBackupsToKeep = 64.00
 	ConfigMaxWorkers          = 32.00
 	ExplorerExe               = "explorer"
+	LinuxExplorerExe          = "xdg-open"
 	SteamNoitaFlags           = "steam://rungameid/881100"
 	TimeFormat                = "2006-01-02-15-04-05"
+	stagingExtension          = ".partial"
+	quarantineDir             = ".quarantine"
+	staleStagingAge           = time.Hour
+	quarantineMaxAge          = 7 * 24 * time.Hour
 )
 
 type Backup struct {
@@ -26,25 +32,28 @@ type Backup struct {
 	fileCounter       int
 	srcPath           string
 	dstPath           string
+	format            string
 	phase             int
 	timestamp         time.Time
 	sortedBackupDirs  []time.Time
+	detector          ProcessDetector
 	LogRing           *LogRing
 }
 
-func NewBackup(async, autoLaunchChecked bool, maxBackups int, srcPath, dstPath string) *Backup {
+func NewBackup(async, autoLaunchChecked bool, maxBackups int, srcPath, dstPath string, detector ProcessDetector) *Backup {
 	return &Backup{
 		async:             async,
 		autoLaunchChecked: autoLaunchChecked,
 		maxBackups:        maxBackups,
 		srcPath:           srcPath,
 		dstPath:           dstPath,
+		detector:          detector,
 		LogRing:           NewLogRing(1),
 	}
 }
 
 func (b *Backup) BackupNoita() {
-	if !isNoitaRunning() {
+	if process, running := b.detector.NoitaProcess(); !running {
 		if b.phase == stopped {
 			if b.async {
 				go func() { _ = b.backupNoita() }()
@@ -55,21 +64,26 @@ func (b *Backup) BackupNoita() {
 			b.LogRing.LogAndAppend(ErrOperationAlreadyInProgress)
 		}
 	} else {
-		b.LogRing.LogAndAppend(fmt.Sprintf("%s %s", ErrNoitaRunning, ErrDuringBackup))
+		b.LogRing.LogAndAppend(noitaRunningError(process, ErrDuringBackup))
 	}
 }
 
 func (b *Backup) backupNoita() error {
 	b.timestamp = time.Now()
+	b.format = getBackupFormat()
 	b.phase = started
 	b.reportStart()
 
-	newBackupPath := fmt.Sprintf("%s\\%s", b.dstPath, b.timestamp.Format(TimeFormat))
+	// everything is written to a staging path first and only renamed into place once
+	// complete, so an interrupted backup never looks like a real one
+	newBackupPath := b.newBackupPath()
+	stagingPath := newBackupPath + stagingExtension
+	CleanStaging(b.LogRing, b.dstPath)
 
 	// get current number of backups
 	curNumBackups, err := getNumBackups(b.dstPath)
 	if err != nil {
-		return b.backupPost(newBackupPath, fmt.Sprintf("%s: %v", ErrErrorGettingBackups, err))
+		return b.backupPost(stagingPath, fmt.Sprintf("%s: %v", ErrErrorGettingBackups, err))
 	} else {
 		b.LogRing.LogAndAppend(fmt.Sprintf("%s: %d", InfoNumberOfBackups, curNumBackups))
 	}
@@ -79,38 +93,81 @@ func (b *Backup) backupNoita() error {
 		b.maxBackups = ConfigMaxNumBackupsToKeep
 	}
 
-	// clean up backups
-	if curNumBackups >= b.maxBackups {
+	// clean up backups, a retention policy is applied once this backup is complete
+	policy := getRetentionPolicy()
+	if !policy.Enabled() && curNumBackups >= b.maxBackups {
 		b.LogRing.LogAndAppend(ErrMaxBackupsExceeded)
 
 		// get and sort backup directories
 		// oldest are first in the sorted slice
 		b.sortedBackupDirs, err = getBackupDirs(b.dstPath, TimeFormat)
 		if err != nil {
-			return b.backupPost(newBackupPath, fmt.Sprintf("%s: %v", ErrErrorGettingBackups, err))
+			return b.backupPost(stagingPath, fmt.Sprintf("%s: %v", ErrErrorGettingBackups, err))
 		}
 
 		// clean backup directories to make room for this backup
 		if err := b.cleanBackups(); err != nil {
-			return b.backupPost(newBackupPath, fmt.Sprintf("%s: %v", ErrFailureDeletingBackups, err))
+			return b.backupPost(stagingPath, fmt.Sprintf("%s: %v", ErrFailureDeletingBackups, err))
 		}
 	}
 
-	// create new backup path
-	if err := createIfNotExists(newBackupPath, 0755); err != nil {
-		return b.backupPost(newBackupPath, fmt.Sprintf("%s: %v", ErrCannotCreateDestination, err))
+	switch b.format {
+	case FormatRepo:
+		// store changed file contents once and write a snapshot manifest
+		if err := storeSnapshot(b.srcPath, stagingPath, &b.dirCounter, &b.fileCounter, viper.GetInt("num-workers"), b.newManifest); err != nil {
+			return b.backupPost(stagingPath, fmt.Sprintf("%s: %v", ErrWorkerFailed, err))
+		}
+	case FormatZip:
+		// write source into a single archive
+		if err := zipDirectory(b.srcPath, stagingPath, &b.dirCounter, &b.fileCounter, b.newManifest); err != nil {
+			return b.backupPost(stagingPath, fmt.Sprintf("%s: %v", ErrCreatingArchive, err))
+		}
+	default:
+		// create new backup path
+		if err := createIfNotExists(stagingPath, 0755); err != nil {
+			return b.backupPost(stagingPath, fmt.Sprintf("%s: %v", ErrCannotCreateDestination, err))
+		}
+
+		// hardlink unchanged files against the newest directory backup
+		linkDest := ""
+		if viper.GetBool(ViperIncremental) {
+			linkDest, err = getLatestDirBackup(b.dstPath)
+			if err != nil {
+				return b.backupPost(stagingPath, fmt.Sprintf("%s: %v", ErrErrorGettingBackups, err))
+			}
+			if linkDest != "" {
+				b.LogRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoLinkDest, linkDest))
+			}
+		}
+
+		// recursively copy source to destination
+		if err := concurrentCopy(b.srcPath, stagingPath, linkDest, "", &b.dirCounter, &b.fileCounter, viper.GetInt("num-workers")); err != nil {
+			return b.backupPost(stagingPath, fmt.Sprintf("%s: %v", ErrWorkerFailed, err))
+		}
+
+		// record what was copied next to the copied files
+		if err := b.writeDirManifest(stagingPath); err != nil {
+			return b.backupPost(stagingPath, fmt.Sprintf("%s: %v", ErrWritingManifest, err))
+		}
 	}
 
-	// recursively copy source to destination
-	if err := concurrentCopy(b.srcPath, newBackupPath, &b.dirCounter, &b.fileCounter, viper.GetInt("num-workers")); err != nil {
-		return b.backupPost(newBackupPath, fmt.Sprintf("%s: %v", ErrWorkerFailed, err))
+	// move the completed backup into place
+	if err := os.Rename(stagingPath, newBackupPath); err != nil {
+		return b.backupPost(stagingPath, fmt.Sprintf("%s: %v", ErrFinalizingBackup, err))
+	}
+
+	// apply the retention policy including this backup
+	if policy.Enabled() {
+		if _, err := b.Prune(false); err != nil {
+			b.LogRing.LogAndAppend(fmt.Sprintf("%s: %v", ErrFailureDeletingBackups, err))
+		}
 	}
 
 	b.reportStop()
 	b.resetPhase()
 
 	if b.autoLaunchChecked {
-		err = LaunchNoita(b.async)
+		err = LaunchNoita(b.async, b.detector)
 		if err != nil {
 			b.LogRing.LogAndAppend(fmt.Sprintf("%s: %v", ErrFailedToLaunch, err))
 		}
@@ -119,6 +176,53 @@ func (b *Backup) backupNoita() error {
 	return nil
 }
 
+func (b *Backup) newBackupPath() string {
+	path := filepath.Join(b.dstPath, b.timestamp.Format(TimeFormat))
+	switch b.format {
+	case FormatZip:
+		path += zipExtension
+	case FormatRepo:
+		path += snapshotExtension
+	default:
+	}
+
+	return path
+}
+
+// newManifest records the provenance of the running backup together with its entries.
+func (b *Backup) newManifest(entries []ManifestEntry) *Manifest {
+	metadata, err := inspectSave(b.srcPath)
+	if err != nil {
+		b.LogRing.LogAndAppend(err.Error())
+	}
+
+	return &Manifest{
+		Timestamp: b.timestamp,
+		Version:   Version,
+		Format:    b.format,
+		Source:    b.srcPath,
+		Workers:   viper.GetInt(ViperNumWorkers),
+		Duration:  time.Since(b.timestamp).String(),
+		Dirs:      b.dirCounter,
+		Files:     b.fileCounter,
+		Metadata:  metadata,
+		Entries:   entries,
+	}
+}
+
+func (b *Backup) writeDirManifest(backupPath string) error {
+	entries, err := walkManifestEntries(backupPath)
+	if err != nil {
+		return err
+	}
+
+	if err := hashManifestEntries(backupPath, entries, viper.GetInt(ViperNumWorkers)); err != nil {
+		return err
+	}
+
+	return writeManifest(filepath.Join(backupPath, manifestFileName), b.newManifest(entries))
+}
+
 func (b *Backup) resetPhase() {
 	b.phase = stopped
 	b.dirCounter = 0
@@ -128,7 +232,7 @@ func (b *Backup) resetPhase() {
 func (b *Backup) reportStart() {
 	b.LogRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoTimestamp, b.timestamp.Format(LogRingTimeFormat)))
 	b.LogRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoSource, b.srcPath))
-	b.LogRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoDestination, fmt.Sprintf("%s\\%s", b.dstPath, b.timestamp.Format(TimeFormat))))
+	b.LogRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoDestination, b.newBackupPath()))
 }
 
 func (b *Backup) reportStop() {
@@ -143,11 +247,13 @@ func (b *Backup) cleanBackups() error {
 		return fmt.Errorf(ErrInvalidBackups)
 	}
 
-	totalBackups := len(b.sortedBackupDirs)
+	// pinned backups are never removed and do not count against maxBackups
+	_, unpinned := splitPinned(b.dstPath, b.sortedBackupDirs)
+	totalBackups := len(unpinned)
 	totalToRemove := totalBackups - (b.maxBackups - 1)
 
 	for i := 0; i < totalToRemove; i++ {
-		folder := fmt.Sprintf("%s\\%s", b.dstPath, b.sortedBackupDirs[i].Format(TimeFormat))
+		folder := getBackupPath(b.dstPath, unpinned[i])
 		b.LogRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoRemovingBackup, folder))
 		err := os.RemoveAll(folder)
 		if err != nil {
@@ -155,6 +261,19 @@ func (b *Backup) cleanBackups() error {
 		}
 	}
 
+	return b.collectObjects()
+}
+
+// collectObjects releases objects that are no longer referenced by any snapshot.
+func (b *Backup) collectObjects() error {
+	removed, err := gcObjects(b.dstPath)
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		b.LogRing.LogAndAppend(fmt.Sprintf("%s: %d", InfoRemovedObjects, removed))
+	}
+
 	return nil
 }
 
@@ -184,6 +303,12 @@ func getBackupDirs(backupPath, timePattern string) ([]time.Time, error) {
 		}
 
 		for _, entry := range entries {
+			// skip repository bookkeeping such as the object store and backups that
+			// are still being written
+			if strings.HasPrefix(entry.Name(), ".") || strings.HasSuffix(entry.Name(), stagingExtension) {
+				continue
+			}
+
 			srcPath := filepath.Join(backupPath, entry.Name())
 			srcInfo, err := os.Stat(srcPath)
 			if err != nil {
@@ -192,13 +317,23 @@ func getBackupDirs(backupPath, timePattern string) ([]time.Time, error) {
 
 			switch srcInfo.Mode() & os.ModeType {
 			case os.ModeDir:
-				name := strings.Split(srcPath, "\\")
-				nameDate, err := time.Parse(timePattern, name[len(name)-1])
+				nameDate, err := time.Parse(timePattern, entry.Name())
 				if err != nil {
 					return backupDirs, err
 				}
 				backupDirs = append(backupDirs, nameDate)
 			default:
+				// zip archives and repository snapshots are backups as well, other archives
+				// a user keeps next to them are not
+				ext := filepath.Ext(entry.Name())
+				if ext != zipExtension && ext != snapshotExtension {
+					continue
+				}
+				nameDate, err := time.Parse(timePattern, strings.TrimSuffix(entry.Name(), ext))
+				if err != nil {
+					continue
+				}
+				backupDirs = append(backupDirs, nameDate)
 			}
 		}
 		sort.Sort(ByDate(backupDirs))
@@ -206,6 +341,199 @@ func getBackupDirs(backupPath, timePattern string) ([]time.Time, error) {
 	}
 }
 
+// findBackup returns the backup in the sorted backupDirs selected by id, which is one of
+//   - an empty id or latest for the newest backup
+//   - latest~N for the Nth backup before the newest
+//   - an exact backup timestamp such as 2024-06-12-17-49-18
+//   - a timestamp prefix such as 2024-06-12 for the newest backup matching it
+func findBackup(backupDirs []time.Time, id string) (time.Time, error) {
+	if len(backupDirs) == 0 {
+		return time.Time{}, fmt.Errorf(ErrNoBackupDirs)
+	}
+
+	if id == "" || id == StrLatest {
+		return backupDirs[len(backupDirs)-1], nil
+	}
+
+	if offset, ok := strings.CutPrefix(id, StrLatest+"~"); ok {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf(ErrInvalidSelector, id)
+		}
+		if n >= len(backupDirs) {
+			return time.Time{}, fmt.Errorf(ErrBackupNotFound, id)
+		}
+		return backupDirs[len(backupDirs)-1-n], nil
+	}
+
+	for i := len(backupDirs) - 1; i >= 0; i-- {
+		if strings.HasPrefix(backupDirs[i].Format(TimeFormat), id) {
+			return backupDirs[i], nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf(ErrBackupNotFound, id)
+}
+
+// findBackupByID returns the backup in backupPath matching id.
+func findBackupByID(backupPath, id string) (time.Time, error) {
+	backupDirs, err := getBackupDirs(backupPath, TimeFormat)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %v", ErrFailedGettingBackupDirs, err)
+	}
+
+	return findBackup(backupDirs, id)
+}
+
+func getBackupFormat() string {
+	format := viper.GetString(ViperBackupFormat)
+	if format == "" {
+		return FormatDir
+	}
+
+	return format
+}
+
+// getBackupPath returns the on disk location of the backup taken at timestamp, which
+// is either a plain directory, a zip archive or a repository snapshot.
+func getBackupPath(backupPath string, timestamp time.Time) string {
+	path := filepath.Join(backupPath, timestamp.Format(TimeFormat))
+	for _, ext := range []string{zipExtension, snapshotExtension} {
+		if exists(path + ext) {
+			return path + ext
+		}
+	}
+
+	return path
+}
+
+// QuarantineStagingDirs moves staging paths left behind by interrupted backups out of
+// the way into the quarantine directory of backupPath and returns the moved paths.  Only
+// staging paths untouched for staleStagingAge are moved, as younger ones may still be
+// written by a backup running in another process.
+func QuarantineStagingDirs(backupPath string) ([]string, error) {
+	var quarantined []string
+
+	entries, err := os.ReadDir(backupPath)
+	if err != nil {
+		return quarantined, err
+	}
+
+	quarantine := filepath.Join(backupPath, quarantineDir)
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), stagingExtension) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return quarantined, err
+		}
+		if time.Since(info.ModTime()) < staleStagingAge {
+			continue
+		}
+
+		if err := createIfNotExists(quarantine, Mode0755); err != nil {
+			return quarantined, err
+		}
+
+		path := filepath.Join(quarantine, entry.Name())
+		if err := os.Rename(filepath.Join(backupPath, entry.Name()), path); err != nil {
+			return quarantined, err
+		}
+		quarantined = append(quarantined, path)
+	}
+
+	return quarantined, nil
+}
+
+// QuarantinedBackups returns the paths of the interrupted backups in the quarantine
+// directory of backupPath.
+func QuarantinedBackups(backupPath string) ([]string, error) {
+	var quarantined []string
+
+	quarantine := filepath.Join(backupPath, quarantineDir)
+	entries, err := os.ReadDir(quarantine)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return quarantined, nil
+		}
+		return quarantined, err
+	}
+
+	for _, entry := range entries {
+		quarantined = append(quarantined, filepath.Join(quarantine, entry.Name()))
+	}
+
+	return quarantined, nil
+}
+
+// CleanQuarantine removes interrupted backups that were quarantined more than
+// quarantineMaxAge ago and returns their paths.  With dryRun nothing is removed.
+func CleanQuarantine(backupPath string, dryRun bool) ([]string, error) {
+	var removed []string
+
+	quarantined, err := QuarantinedBackups(backupPath)
+	if err != nil {
+		return removed, err
+	}
+
+	for _, path := range quarantined {
+		info, err := os.Lstat(path)
+		if err != nil {
+			return removed, err
+		}
+		if time.Since(info.ModTime()) < quarantineMaxAge {
+			continue
+		}
+		if !dryRun {
+			if err := os.RemoveAll(path); err != nil {
+				return removed, err
+			}
+		}
+		removed = append(removed, path)
+	}
+
+	return removed, nil
+}
+
+// CleanStaging quarantines stale staging paths and removes old quarantined backups, it
+// runs on startup and before a new backup is staged.
+func CleanStaging(logRing *LogRing, backupPath string) {
+	quarantined, err := QuarantineStagingDirs(backupPath)
+	if err != nil {
+		logRing.LogAndAppend(fmt.Sprintf("%s: %v", ErrQuarantiningStaging, err))
+	}
+	for _, path := range quarantined {
+		logRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoQuarantined, path))
+	}
+
+	removed, err := CleanQuarantine(backupPath, false)
+	if err != nil {
+		logRing.LogAndAppend(fmt.Sprintf("%s: %v", ErrQuarantiningStaging, err))
+	}
+	for _, path := range removed {
+		logRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoPurgedBackup, path))
+	}
+}
+
+// getLatestDirBackup returns the path of the newest backup stored as a plain
+// directory, or an empty string when there is none.
+func getLatestDirBackup(backupPath string) (string, error) {
+	backupDirs, err := getBackupDirs(backupPath, TimeFormat)
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(backupDirs) - 1; i >= 0; i-- {
+		path := getBackupPath(backupPath, backupDirs[i])
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return path, nil
+		}
+	}
+
+	return "", nil
+}
+
 func getNumBackups(backupPath string) (int, error) {
 	numBackup := 0
 	backupDirs, err := getBackupDirs(backupPath, TimeFormat)
@@ -213,7 +541,8 @@ func getNumBackups(backupPath string) (int, error) {
 		return numBackup, err
 	}
 
-	return len(backupDirs), nil
+	_, unpinned := splitPinned(backupPath, backupDirs)
+	return len(unpinned), nil
 }
 
 type ByDate []time.Time
@@ -1,16 +1,59 @@
 package internal
 
 import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"github.com/spf13/viper"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
 
 const (
 	TestBackupPath = "test_backup_path"
+	TestSteamEnv   = "NOITABACKUP_TEST_STEAM"
 )
 
+// TestMain lets a copy of the test binary stand in for the steam executable, it exits
+// right away when started with TestSteamEnv set.
+func TestMain(m *testing.M) {
+	if os.Getenv(TestSteamEnv) != "" {
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// fakeProcessDetector reports Noita as running or not without looking at real processes.
+type fakeProcessDetector struct {
+	running bool
+}
+
+func (f fakeProcessDetector) NoitaProcess() (Process, bool) {
+	if !f.running {
+		return Process{}, false
+	}
+	return Process{PID: 4242, StartTime: time.Now()}, true
+}
+
+// scriptedProcessDetector reports the given states one poll after another and keeps
+// reporting the last one.
+type scriptedProcessDetector struct {
+	states []bool
+}
+
+func (s *scriptedProcessDetector) NoitaProcess() (Process, bool) {
+	running := s.states[0]
+	if len(s.states) > 1 {
+		s.states = s.states[1:]
+	}
+	return fakeProcessDetector{running: running}.NoitaProcess()
+}
+
 func TestByDate(t *testing.T) {
 	tests := []struct {
 		name string
@@ -46,7 +89,7 @@ func TestCleanBackups(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			b := NewBackup(false, false, tt.numToKeep, "", TestBackupPath)
+			b := NewBackup(false, false, tt.numToKeep, "", TestBackupPath, fakeProcessDetector{})
 			err := b.cleanBackups()
 			if (err != nil) != tt.expectErr {
 				t.Fatalf("cleanBackups() error = %v, expected %v", err, tt.expectErr)
@@ -73,3 +116,1003 @@ func createMockBackupDirs(t *testing.T) []time.Time {
 	}
 	return dirs
 }
+
+func TestGetBackupDirs(t *testing.T) {
+	mockBackupDirs := createMockBackupDirs(t)
+
+	// add a zip archive backup newer than every directory backup
+	archive := mockBackupDirs[len(mockBackupDirs)-1].Add(time.Minute)
+	if err := os.WriteFile(filepath.Join(TestBackupPath, archive.Format(TimeFormat)+zipExtension), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// archives not named by a timestamp are left alone
+	for _, name := range []string{"mods" + zipExtension, "old" + snapshotExtension} {
+		if err := os.WriteFile(filepath.Join(TestBackupPath, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backupDirs, err := getBackupDirs(TestBackupPath, TimeFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backupDirs) != len(mockBackupDirs)+1 {
+		t.Fatalf("getBackupDirs() returned %d backups, expected %d", len(backupDirs), len(mockBackupDirs)+1)
+	}
+	if got := getBackupPath(TestBackupPath, backupDirs[len(backupDirs)-1]); !strings.HasSuffix(got, zipExtension) {
+		t.Errorf("getBackupPath() = %s, expected a zip archive", got)
+	}
+
+	err = os.RemoveAll(TestBackupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZipDirectory(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "persistent", "flags"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "persistent", "flags", "card_unlocked_nuke"), []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "backup"+zipExtension)
+	b := NewBackup(false, false, 1, src, "", fakeProcessDetector{})
+	if err := zipDirectory(src, archive, &b.dirCounter, &b.fileCounter, b.newManifest); err != nil {
+		t.Fatal(err)
+	}
+	if b.dirCounter != 2 || b.fileCounter != 1 {
+		t.Fatalf("zipDirectory() counted %d dirs and %d files, expected 2 and 1", b.dirCounter, b.fileCounter)
+	}
+
+	var dirs, files int
+	if err := extractZip(archive, dst, "", &dirs, &files); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dst, "persistent", "flags", "card_unlocked_nuke"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "1" {
+		t.Errorf("extracted file = %q, expected %q", got, "1")
+	}
+
+	m, err := readManifest(filepath.Join(dst, manifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Entries) != 3 || m.Files != 1 || m.Dirs != 2 {
+		t.Errorf("manifest holds %d entries, %d files and %d dirs, expected 3, 1 and 2", len(m.Entries), m.Files, m.Dirs)
+	}
+
+	// restores leave the manifest out of the save
+	save := t.TempDir()
+	if err := extractZip(archive, save, manifestFileName, &dirs, &files); err != nil {
+		t.Fatal(err)
+	}
+	if exists(filepath.Join(save, manifestFileName)) {
+		t.Errorf("extractZip() extracted the excluded %s", manifestFileName)
+	}
+}
+
+func TestStoreSnapshot(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	for _, name := range []string{"player.xml", "world_state.xml"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte("same"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := time.Date(2024, 6, 12, 17, 49, 18, 0, time.UTC)
+	second := first.Add(time.Minute)
+	for _, ts := range []time.Time{first, second} {
+		var dirs, files int
+		path := filepath.Join(dst, ts.Format(TimeFormat)+snapshotExtension)
+		b := NewBackup(false, false, 2, src, dst, fakeProcessDetector{})
+		b.timestamp = ts
+		if err := storeSnapshot(src, path, &dirs, &files, 2, b.newManifest); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// two snapshots of two identical files share a single object
+	refCounts, err := countObjectReferences(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refCounts) != 1 {
+		t.Fatalf("object store holds %d objects, expected 1", len(refCounts))
+	}
+
+	var dirs, files int
+	restored := t.TempDir()
+	if err := restoreSnapshot(filepath.Join(dst, second.Format(TimeFormat)+snapshotExtension), restored, &dirs, &files, 2); err != nil {
+		t.Fatal(err)
+	}
+	if files != 2 {
+		t.Errorf("restoreSnapshot() restored %d files, expected 2", files)
+	}
+
+	// paths outside dst and objects that no longer match their hash are refused
+	escaping := filepath.Join(t.TempDir(), "escaping"+snapshotExtension)
+	if err := writeManifest(escaping, &Manifest{Entries: []ManifestEntry{{Path: "../player.xml", SHA256: strings.Repeat("0", 64)}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreSnapshot(escaping, t.TempDir(), &dirs, &files, 2); err == nil || !strings.Contains(err.Error(), ErrInvalidArchiveEntry) {
+		t.Errorf("restoreSnapshot() = %v, expected %q", err, ErrInvalidArchiveEntry)
+	}
+	for object := range refCounts {
+		if err := os.WriteFile(objectPath(filepath.Join(dst, repoObjectsDir), object), []byte("rotten"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := restoreSnapshot(filepath.Join(dst, second.Format(TimeFormat)+snapshotExtension), t.TempDir(), &dirs, &files, 2); err == nil || !strings.Contains(err.Error(), ErrObjectMismatch) {
+		t.Errorf("restoreSnapshot() = %v, expected %q", err, ErrObjectMismatch)
+	}
+
+	// objects are only collected once no snapshot references them
+	b := NewBackup(false, false, 2, src, dst, fakeProcessDetector{})
+	b.sortedBackupDirs = []time.Time{first, second}
+	if err := b.cleanBackups(); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := gcObjects(dst); err != nil || removed != 0 {
+		t.Fatalf("gcObjects() = %d, %v, expected the object to be kept", removed, err)
+	}
+	// a snapshot still being staged keeps its objects, as do objects stored too recently
+	// to be referenced yet
+	snapshot := filepath.Join(dst, second.Format(TimeFormat)+snapshotExtension)
+	if err := os.Rename(snapshot, snapshot+stagingExtension); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-2 * staleStagingAge)
+	for object := range refCounts {
+		if err := os.Chtimes(objectPath(filepath.Join(dst, repoObjectsDir), object), stale, stale); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if removed, err := gcObjects(dst); err != nil || removed != 0 {
+		t.Fatalf("gcObjects() = %d, %v, expected the object of the staged snapshot to be kept", removed, err)
+	}
+	if err := os.Remove(snapshot + stagingExtension); err != nil {
+		t.Fatal(err)
+	}
+	fresh := objectPath(filepath.Join(dst, repoObjectsDir), strings.Repeat("0", 64))
+	writeFiles(t, map[string]string{fresh: "fresh"})
+	if removed, err := gcObjects(dst); err != nil || removed != 1 {
+		t.Fatalf("gcObjects() = %d, %v, expected 1 object removed", removed, err)
+	}
+	if !exists(fresh) {
+		t.Error("gcObjects() removed an object that may be about to be referenced")
+	}
+}
+
+func TestConcurrentCopyLinkDest(t *testing.T) {
+	src := t.TempDir()
+	for name, content := range map[string]string{"unchanged.xml": "same", "changed.xml": "old"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var dirs, files int
+	previous := t.TempDir()
+	if err := concurrentCopy(src, previous, "", "", &dirs, &files, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite the changed file with the same size but a different modification time
+	if err := os.WriteFile(filepath.Join(src, "changed.xml"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(src, "changed.xml"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	current := t.TempDir()
+	if err := concurrentCopy(src, current, previous, "", &dirs, &files, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"unchanged.xml", true},
+		{"changed.xml", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prevInfo, err := os.Stat(filepath.Join(previous, tt.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			curInfo, err := os.Stat(filepath.Join(current, tt.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := os.SameFile(prevInfo, curInfo); got != tt.expected {
+				t.Errorf("hardlinked = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBackupNoita_Manifest(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "player.xml"), []byte("<Entity/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Set(ViperNumWorkers, 2)
+	defer viper.Set(ViperNumWorkers, nil)
+
+	b := NewBackup(false, false, 16, src, dst, fakeProcessDetector{})
+	if err := b.backupNoita(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := readManifest(filepath.Join(getBackupPath(dst, b.timestamp), manifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Source != src || m.Files != 1 || len(m.Entries) != 1 {
+		t.Fatalf("manifest = %+v, expected a single file from %s", m, src)
+	}
+	if m.Entries[0].SHA256 == "" {
+		t.Errorf("manifest entry %s has no hash", m.Entries[0].Path)
+	}
+	if m.Metadata == nil || m.Metadata.Biome != "Surface" {
+		t.Errorf("manifest metadata = %+v, expected the player inspected from player.xml", m.Metadata)
+	}
+}
+
+func TestVerifyBackup(t *testing.T) {
+	viper.Set(ViperNumWorkers, 2)
+	defer viper.Set(ViperNumWorkers, nil)
+	defer viper.Set(ViperBackupFormat, nil)
+
+	src := t.TempDir()
+	for name, content := range map[string]string{"player.xml": "<Entity/>", "world_state.xml": "<Entity/>"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, format := range BackupFormats {
+		t.Run(format, func(t *testing.T) {
+			viper.Set(ViperBackupFormat, format)
+			dst := t.TempDir()
+			b := NewBackup(false, false, 16, src, dst, fakeProcessDetector{})
+			if err := b.backupNoita(); err != nil {
+				t.Fatal(err)
+			}
+
+			result := verifyBackup(getBackupPath(dst, b.timestamp), b.timestamp, 2)
+			if !result.OK() {
+				t.Fatalf("verifyBackup() = %v, expected no problems", result.Problems())
+			}
+		})
+	}
+
+	t.Run("tampered", func(t *testing.T) {
+		viper.Set(ViperBackupFormat, FormatDir)
+		dst := t.TempDir()
+		b := NewBackup(false, false, 16, src, dst, fakeProcessDetector{})
+		if err := b.backupNoita(); err != nil {
+			t.Fatal(err)
+		}
+
+		path := getBackupPath(dst, b.timestamp)
+		if err := os.WriteFile(filepath.Join(path, "player.xml"), []byte("<Entity >"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "world_state.xml"), []byte("<"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "extra.xml"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		result := verifyBackup(path, b.timestamp, 2)
+		if len(result.Mismatched) != 1 || len(result.Truncated) != 1 || len(result.Extra) != 1 {
+			t.Errorf("verifyBackup() = %v, expected one mismatched, truncated and extra file", result.Problems())
+		}
+	})
+
+	t.Run("no manifest", func(t *testing.T) {
+		timestamp := time.Now()
+		path := getBackupPath(t.TempDir(), timestamp)
+		writeFiles(t, map[string]string{filepath.Join(path, "player.xml"): "<Entity/>"})
+
+		result := verifyBackup(path, timestamp, 2)
+		if !result.OK() || !result.Unverifiable {
+			t.Errorf("verifyBackup() = %+v, expected an unverifiable backup without problems", result)
+		}
+	})
+
+	t.Run("zip file list", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for name, content := range map[string]string{"player.xml": "<Entity/>", "world_state.xml": "<World/>"} {
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write([]byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		timestamp := time.Now()
+		for _, tc := range []struct {
+			name       string
+			content    []byte
+			mismatched int
+		}{
+			{"intact", buf.Bytes(), 0},
+			{"corrupt", bytes.Replace(buf.Bytes(), []byte("<World/>"), []byte("<WORLD/>"), 1), 1},
+		} {
+			path := filepath.Join(t.TempDir(), timestamp.Format(TimeFormat)+zipExtension)
+			writeFiles(t, map[string]string{path: string(tc.content)})
+
+			result := verifyBackup(path, timestamp, 2)
+			if result.Unverifiable || len(result.Mismatched) != tc.mismatched || result.OK() != (tc.mismatched == 0) {
+				t.Errorf("%s: verifyBackup() = %+v, expected %d mismatched files", tc.name, result, tc.mismatched)
+			}
+		}
+	})
+}
+
+func TestQuarantineStagingDirs(t *testing.T) {
+	dst := t.TempDir()
+	complete := time.Date(2024, 6, 12, 17, 49, 18, 0, time.UTC)
+	interrupted := complete.Add(time.Minute)
+	if err := os.MkdirAll(filepath.Join(dst, complete.Format(TimeFormat)), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	running := interrupted.Add(time.Minute)
+	for _, ts := range []time.Time{interrupted, running} {
+		if err := os.MkdirAll(filepath.Join(dst, ts.Format(TimeFormat)+stagingExtension), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// only the interrupted backup has not been written to for a while
+	stale := time.Now().Add(-2 * staleStagingAge)
+	if err := os.Chtimes(filepath.Join(dst, interrupted.Format(TimeFormat)+stagingExtension), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	// an interrupted backup is never picked as the latest
+	backupDirs, err := getBackupDirs(dst, TimeFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backupDirs) != 1 || !backupDirs[0].Equal(complete) {
+		t.Fatalf("getBackupDirs() = %v, expected only %v", backupDirs, complete)
+	}
+
+	quarantined, err := QuarantineStagingDirs(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quarantined) != 1 || !exists(quarantined[0]) {
+		t.Fatalf("QuarantineStagingDirs() = %v, expected the interrupted backup", quarantined)
+	}
+	if exists(filepath.Join(dst, interrupted.Format(TimeFormat)+stagingExtension)) {
+		t.Errorf("interrupted backup is still in the backup directory")
+	}
+	if !exists(filepath.Join(dst, running.Format(TimeFormat)+stagingExtension)) {
+		t.Errorf("QuarantineStagingDirs() moved a backup that may still be running")
+	}
+
+	// quarantined backups are reported and removed once they are old enough
+	if listed, err := QuarantinedBackups(dst); err != nil || !reflect.DeepEqual(listed, quarantined) {
+		t.Errorf("QuarantinedBackups() = %v, %v, expected %v", listed, err, quarantined)
+	}
+	if removed, err := CleanQuarantine(dst, false); err != nil || len(removed) != 0 {
+		t.Errorf("CleanQuarantine() = %v, %v, expected to keep the recent quarantine", removed, err)
+	}
+	old := time.Now().Add(-2 * quarantineMaxAge)
+	if err := os.Chtimes(quarantined[0], old, old); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := CleanQuarantine(dst, true); err != nil || len(removed) != 1 || !exists(quarantined[0]) {
+		t.Errorf("CleanQuarantine() dry run = %v, %v, expected to report without removing", removed, err)
+	}
+	if removed, err := CleanQuarantine(dst, false); err != nil || len(removed) != 1 || exists(quarantined[0]) {
+		t.Errorf("CleanQuarantine() = %v, %v, expected the old quarantine removed", removed, err)
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	// two backups per hour over three days
+	var backupDirs []time.Time
+	start := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	for h := 0; h < 72; h++ {
+		backupDirs = append(backupDirs, start.Add(time.Duration(h)*time.Hour), start.Add(time.Duration(h)*time.Hour+30*time.Minute))
+	}
+
+	tests := []struct {
+		name     string
+		policy   RetentionPolicy
+		expected int
+	}{
+		{"keep last", RetentionPolicy{Last: 5}, 5},
+		{"keep hourly", RetentionPolicy{Hourly: 5}, 5},
+		{"keep daily", RetentionPolicy{Daily: 7}, 3},
+		{"keep last overlaps hourly", RetentionPolicy{Last: 2, Hourly: 2}, 3},
+		{"keep daily and monthly", RetentionPolicy{Daily: 2, Monthly: 1}, 2},
+	}
+	// the first backup of the last day has a newer one that day, the first day is too old
+	decisions := applyRetention(backupDirs, RetentionPolicy{Daily: 2})
+	for i, expected := range map[int]string{
+		len(backupDirs) - 48: fmt.Sprintf(InfoSuperseded, InfoKeepDaily, "2024-06-12"),
+		0:                    fmt.Sprintf(InfoOutsideKeep, InfoKeepDaily, 2),
+	} {
+		if decisions[i].Keep || !reflect.DeepEqual(decisions[i].Reasons, []string{expected}) {
+			t.Errorf("applyRetention() decided %+v, expected removal because %q", decisions[i], expected)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept := 0
+			for _, decision := range applyRetention(backupDirs, tt.policy) {
+				if len(decision.Reasons) == 0 {
+					t.Errorf("backup %s kept or removed without a reason", decision.ID)
+				}
+				if decision.Keep {
+					kept++
+				}
+			}
+			if kept != tt.expected {
+				t.Errorf("applyRetention() kept %d backups, expected %d", kept, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCleanBackupsPinned(t *testing.T) {
+	mockBackupDirs := createMockBackupDirs(t)
+	defer func() { _ = os.RemoveAll(TestBackupPath) }()
+
+	// pin the oldest backup, it would be the first to be removed otherwise
+	oldest := mockBackupDirs[0].Format(TimeFormat)
+	if _, err := PinBackup(TestBackupPath, oldest); err != nil {
+		t.Fatal(err)
+	}
+
+	numBackups, err := getNumBackups(TestBackupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numBackups != len(mockBackupDirs)-1 {
+		t.Fatalf("getNumBackups() = %d, expected pinned backups not to count", numBackups)
+	}
+
+	b := NewBackup(false, false, 2, "", TestBackupPath, fakeProcessDetector{})
+	if b.sortedBackupDirs, err = getBackupDirs(TestBackupPath, TimeFormat); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.cleanBackups(); err != nil {
+		t.Fatal(err)
+	}
+
+	backupDirs, err := getBackupDirs(TestBackupPath, TimeFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backupDirs) != 2 || backupDirs[0].Format(TimeFormat) != oldest {
+		t.Errorf("getBackupDirs() = %v, expected the pinned and the newest backup", backupDirs)
+	}
+}
+
+func TestListBackups(t *testing.T) {
+	mockBackupDirs := createMockBackupDirs(t)
+	defer func() { _ = os.RemoveAll(TestBackupPath) }()
+
+	newest := mockBackupDirs[len(mockBackupDirs)-1].Format(TimeFormat)
+	if err := os.WriteFile(filepath.Join(TestBackupPath, newest, "player.xml"), []byte("<Entity/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PinBackup(TestBackupPath, newest); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := ListBackups(TestBackupPath, ListFilter{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 3 {
+		t.Fatalf("ListBackups() returned %d backups, expected 3", len(infos))
+	}
+	if infos[0].ID != newest || !infos[0].Pinned || infos[0].Files != 1 || infos[0].LogicalSize != 9 || infos[0].DiskSize != 9 {
+		t.Errorf("ListBackups()[0] = %+v, expected the pinned newest backup holding one file", infos[0])
+	}
+
+	since, err := ParseTimeFilter(mockBackupDirs[5].Format(TimeFormat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if infos, err = ListBackups(TestBackupPath, ListFilter{Since: since}); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 5 {
+		t.Errorf("ListBackups() since %s returned %d backups, expected 5", since, len(infos))
+	}
+}
+
+func TestDiskSizes(t *testing.T) {
+	dst := t.TempDir()
+	older := time.Date(2024, 6, 12, 17, 49, 18, 0, time.UTC)
+	newer := older.Add(time.Minute)
+	zipped := newer.Add(time.Minute)
+	writeFiles(t, map[string]string{
+		filepath.Join(getBackupPath(dst, older), "player.xml"):      "<Entity/>",
+		filepath.Join(getBackupPath(dst, newer), "world_state.xml"): "<World/>",
+		filepath.Join(dst, zipped.Format(TimeFormat)+zipExtension):  "not really a zip",
+	})
+
+	// the newer backup hard links the unchanged file of the older one
+	if err := os.Link(filepath.Join(getBackupPath(dst, older), "player.xml"), filepath.Join(getBackupPath(dst, newer), "player.xml")); err != nil {
+		t.Skipf("cannot create hard link: %v", err)
+	}
+
+	sizes, err := diskSizes(dst, []time.Time{older, newer, zipped})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[time.Time]int64{older: 9, newer: 8, zipped: 16}; !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("diskSizes() = %v, expected %v", sizes, expected)
+	}
+}
+
+func TestBackupNoita_NoitaRunning(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "player.xml"), []byte("<Entity/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBackup(false, false, 16, src, dst, fakeProcessDetector{running: true})
+	b.BackupNoita()
+
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("BackupNoita() created %d entries while Noita was running", len(entries))
+	}
+	if logs := strings.Join(b.LogRing.Print(), "\n"); !strings.Contains(logs, ErrNoitaRunning) || !strings.Contains(logs, "pid 4242") {
+		t.Errorf("BackupNoita() logged %q, expected %q with the process id", logs, ErrNoitaRunning)
+	}
+
+	if err := LaunchNoita(false, fakeProcessDetector{running: true}); err == nil || !strings.Contains(err.Error(), ErrNoitaRunning) {
+		t.Errorf("LaunchNoita() error = %v, expected %q", err, ErrNoitaRunning)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	viper.Set(ViperNumWorkers, 2)
+	defer viper.Set(ViperNumWorkers, nil)
+
+	src := t.TempDir()
+	dst := t.TempDir()
+	player := filepath.Join(src, "player.xml")
+	if err := os.WriteFile(player, []byte("<Entity/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	detector := &fakeProcessDetector{}
+	w := NewWatcher(detector, func() *Backup {
+		return NewBackup(false, false, 16, src, dst, detector)
+	}, time.Second, 10*time.Second, 5*time.Minute, true)
+
+	// play a session and quit, the backup waits for the debounce
+	start := time.Now()
+	session := func(at time.Time) {
+		detector.running = true
+		if w.poll(at) {
+			t.Fatal("poll() backed up while Noita was running")
+		}
+		detector.running = false
+		if w.poll(at.Add(time.Second)) {
+			t.Fatal("poll() backed up before the debounce passed")
+		}
+	}
+
+	session(start)
+	if !w.poll(start.Add(15 * time.Second)) {
+		t.Fatal("poll() did not back up once the exit settled")
+	}
+
+	// an unchanged save is skipped, even after the minimum interval
+	session(start.Add(10 * time.Minute))
+	if w.poll(start.Add(11 * time.Minute)) {
+		t.Error("poll() backed up an unchanged save00")
+	}
+
+	// backups are named by the second, move the first one out of the way
+	backupDirs, err := getBackupDirs(dst, TimeFormat)
+	if err != nil || len(backupDirs) != 1 {
+		t.Fatalf("getBackupDirs() = %v, %v, expected one backup", backupDirs, err)
+	}
+	first := getBackupPath(dst, backupDirs[0])
+	if err := os.Rename(first, getBackupPath(dst, backupDirs[0].Add(-time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+
+	// a changed save is only backed up once the minimum interval passed
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(player, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := saveChanged(src, dst); err != nil || !changed {
+		t.Fatalf("saveChanged() = %v, %v, expected a change", changed, err)
+	}
+	w.lastBackup = start.Add(11 * time.Minute)
+	session(start.Add(12 * time.Minute))
+	if w.poll(start.Add(13 * time.Minute)) {
+		t.Error("poll() backed up within the minimum interval")
+	}
+	session(start.Add(20 * time.Minute))
+	if !w.poll(start.Add(21 * time.Minute)) {
+		t.Error("poll() did not back up a changed save00")
+	}
+	if backupDirs, err = getBackupDirs(dst, TimeFormat); err != nil || len(backupDirs) != 2 {
+		t.Errorf("getBackupDirs() = %v, %v, expected two backups", backupDirs, err)
+	}
+
+	// a failed backup is not reported and does not count towards the minimum interval
+	if err := os.RemoveAll(src); err != nil {
+		t.Fatal(err)
+	}
+	session(start.Add(30 * time.Minute))
+	if w.poll(start.Add(31 * time.Minute)) {
+		t.Error("poll() reported a failed backup as taken")
+	}
+	if !w.lastBackup.Equal(start.Add(21 * time.Minute)) {
+		t.Errorf("poll() moved the last backup to %v after a failed backup", w.lastBackup)
+	}
+}
+
+func TestSession_BackupOnExit(t *testing.T) {
+	// a copy of the test binary is launched as steam and exits right away, under its own
+	// name so it is never mistaken for the test process
+	steam := filepath.Join(t.TempDir(), "steam"+filepath.Ext(os.Args[0]))
+	if err := copyFile(os.Args[0], steam); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(steam, Mode0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(TestSteamEnv, "1")
+	viper.Set(ViperSteamPath, steam)
+	viper.Set(ViperNumWorkers, 2)
+	defer viper.Set(ViperSteamPath, nil)
+	defer viper.Set(ViperNumWorkers, nil)
+
+	src := t.TempDir()
+	dst := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "player.xml"), []byte("<Entity/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// not running before the launch, starting up, playing and finally quitting
+	detector := &scriptedProcessDetector{states: []bool{false, false, true, true, true, false}}
+	session := NewSession(NewBackup(false, true, 16, src, dst, detector), "", true)
+	session.PollInterval = time.Millisecond
+	if err := session.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	backupDirs, err := getBackupDirs(dst, TimeFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backupDirs) != 1 {
+		t.Errorf("Session.Run() took %d backups, expected one after Noita exited", len(backupDirs))
+	}
+
+	// a game that never starts times out instead of waiting forever
+	session = NewSession(NewBackup(false, false, 16, src, dst, fakeProcessDetector{}), "", true)
+	session.PollInterval = time.Millisecond
+	session.StartTimeout = 10 * time.Millisecond
+	if err := session.Run(); err == nil {
+		t.Error("Session.Run() expected an error when Noita never starts")
+	}
+}
+
+func TestInspectSave(t *testing.T) {
+	src := t.TempDir()
+	player := `<Entity name="DEBUG_NAME:player" tags="player_unit">
+  <_Transform position.x="312.5" position.y="3600.25" rotation="0" scale.x="1" scale.y="1"></_Transform>
+  <DamageModelComponent hp="4" max_hp="6.5"></DamageModelComponent>
+  <WalletComponent money="1234"></WalletComponent>
+  <Entity tags="perk_entity"><UIIconComponent name="$perk_extra_perk" is_perk="1"></UIIconComponent></Entity>
+  <Entity tags="perk_entity"><GameEffectComponent effect="PROTECTION_FIRE"></GameEffectComponent></Entity>
+  <Entity name="inventory_quick">
+    <Entity><DamageModelComponent hp="99" max_hp="99"></DamageModelComponent></Entity>
+  </Entity>
+</Entity>`
+	world := `<Entity>
+  <WorldStateComponent>
+    <lua_globals><E key="NEW_GAME_PLUS_COUNT" value="1"></E></lua_globals>
+    <orbs_found_thisrun><primitive value="0"></primitive><primitive value="3"></primitive></orbs_found_thisrun>
+  </WorldStateComponent>
+</Entity>`
+	if err := os.WriteFile(filepath.Join(src, playerFileName), []byte(player), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, worldStateFileName), []byte(world), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := inspectSave(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := SaveMetadata{
+		HP:          100,
+		MaxHP:       162.5,
+		Gold:        1234,
+		X:           312.5,
+		Y:           3600.25,
+		Biome:       "Snowy Depths",
+		Perks:       []string{"EXTRA_PERK", "PROTECTION_FIRE"},
+		Orbs:        2,
+		NewGamePlus: 1,
+	}
+	if got == nil || !reflect.DeepEqual(*got, expected) {
+		t.Errorf("inspectSave() = %+v, expected %+v", got, expected)
+	}
+
+	// between runs there is no player.xml and so no metadata
+	if got, err := inspectSave(t.TempDir()); got != nil || err != nil {
+		t.Errorf("inspectSave() = %+v, %v, expected no metadata", got, err)
+	}
+}
+
+func TestBiomeAt(t *testing.T) {
+	tests := []struct {
+		y        float64
+		expected string
+	}{
+		{-85, "Surface"},
+		{1000, "Mines"},
+		{9000, "The Vault"},
+		{20000, "The Laboratory"},
+	}
+	for _, tt := range tests {
+		if got := BiomeAt(tt.y); got != tt.expected {
+			t.Errorf("BiomeAt(%v) = %q, expected %q", tt.y, got, tt.expected)
+		}
+	}
+}
+
+func TestInspectBackup_Wands(t *testing.T) {
+	dst := t.TempDir()
+	id := "2024-06-12-17-49-18"
+	player := `<Entity name="DEBUG_NAME:player">
+  <DamageModelComponent hp="4" max_hp="4"></DamageModelComponent>
+  <Entity name="inventory_quick">
+    <Entity tags="wand,item">
+      <AbilityComponent ui_name="Bolt staff" mana="120" mana_max="300" mana_charge_speed="90">
+        <gun_config actions_per_round="1" deck_capacity="4" reload_time="30" shuffle_deck_when_empty="0"></gun_config>
+        <gunaction_config fire_rate_wait="6" spread_degrees="-2.5"></gunaction_config>
+      </AbilityComponent>
+      <Entity tags="card_action">
+        <ItemActionComponent action_id="BOUNCE"></ItemActionComponent>
+        <ItemComponent inventory_slot.x="1"></ItemComponent>
+      </Entity>
+      <Entity tags="card_action">
+        <ItemActionComponent action_id="LIGHT_BULLET"></ItemActionComponent>
+        <ItemComponent inventory_slot.x="0"></ItemComponent>
+      </Entity>
+    </Entity>
+    <Entity tags="potion,item"><MaterialInventoryComponent></MaterialInventoryComponent></Entity>
+  </Entity>
+  <Entity name="inventory_full">
+    <Entity tags="card_action"><ItemActionComponent action_id="BLACK_HOLE"></ItemActionComponent></Entity>
+  </Entity>
+</Entity>`
+	if err := os.MkdirAll(filepath.Join(dst, id), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, id, playerFileName), []byte(player), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	inspection, err := InspectBackup(dst, StrLatest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inspection.ID != id || inspection.Inventory == nil || len(inspection.Inventory.Wands) != 1 {
+		t.Fatalf("InspectBackup() = %+v, expected a single wand in %s", inspection, id)
+	}
+
+	wand := inspection.Inventory.Wands[0]
+	if wand.Name != "Bolt staff" || wand.Capacity != 4 || wand.CastDelay != 0.1 || wand.Recharge != 0.5 ||
+		wand.ManaMax != 300 || wand.Spread != -2.5 || wand.Shuffle {
+		t.Errorf("wand = %+v, expected the stats of the bolt staff", wand)
+	}
+	if strings.Join(wand.Spells, ",") != "LIGHT_BULLET,BOUNCE" {
+		t.Errorf("wand spells = %v, expected them in slot order", wand.Spells)
+	}
+	if strings.Join(inspection.Inventory.Spells, ",") != "BLACK_HOLE" {
+		t.Errorf("inventory spells = %v, expected BLACK_HOLE", inspection.Inventory.Spells)
+	}
+}
+
+func TestMetadataFilter(t *testing.T) {
+	m := &SaveMetadata{Biome: "Snowy Depths", Perks: []string{"EXTRA_PERK"}, Orbs: 3}
+
+	tests := []struct {
+		filter    string
+		expected  bool
+		expectErr bool
+	}{
+		{filter: "perk=extra_perk", expected: true},
+		{filter: "perk=GLASS_CANNON", expected: false},
+		{filter: "biome=snowy depths", expected: true},
+		{filter: "orbs=3", expected: true},
+		{filter: "ng=1", expected: false},
+		{filter: "orbs=many", expectErr: true},
+		{filter: "wand=bolt", expectErr: true},
+		{filter: "perk", expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			filter, err := ParseMetadataFilter(tt.filter)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("ParseMetadataFilter() error = %v, expected %v", err, tt.expectErr)
+			}
+			if !tt.expectErr && filter.Match(m) != tt.expected {
+				t.Errorf("Match() = %v, expected %v", !tt.expected, tt.expected)
+			}
+		})
+	}
+
+	if (MetadataFilter{Key: FilterPerk, Value: "EXTRA_PERK"}).Match(nil) {
+		t.Error("Match() matched a backup without metadata")
+	}
+}
+
+func TestDiffProgress(t *testing.T) {
+	dst := t.TempDir()
+	backups := map[string][]string{
+		"2024-06-12-17-49-18": {"card_unlocked_black_hole", "perk_picked_extra_perk", "secret_moon"},
+		"2024-06-13-09-30-00": {"card_unlocked_black_hole", "card_unlocked_nuke", "progress_ending0"},
+	}
+	for id, flags := range backups {
+		dir := filepath.Join(dst, id, "persistent", "flags")
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		for _, flag := range flags {
+			if err := os.WriteFile(filepath.Join(dir, flag), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	progress, err := BackupProgress(dst, "2024-06-12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Total() != 3 || strings.Join(progress.Categories(), ",") != "perks,secrets,spells" {
+		t.Errorf("BackupProgress() = %+v, expected one perk, secret and spell", progress)
+	}
+
+	diff, err := DiffProgress(dst, "latest~1", "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedGained := map[string][]string{"spells": {"card_unlocked_nuke"}, "progress": {"progress_ending0"}}
+	expectedLost := map[string][]string{"perks": {"perk_picked_extra_perk"}, "secrets": {"secret_moon"}}
+	if !reflect.DeepEqual(diff.Gained, expectedGained) || !reflect.DeepEqual(diff.Lost, expectedLost) {
+		t.Errorf("DiffProgress() gained %v and lost %v, expected %v and %v", diff.Gained, diff.Lost, expectedGained, expectedLost)
+	}
+}
+
+func TestDiffSaves(t *testing.T) {
+	dst, src := t.TempDir(), t.TempDir()
+	player := `<Entity tags="player_unit">
+  <_Transform position.x="0" position.y="%d"></_Transform>
+  <DamageModelComponent hp="4" max_hp="4"></DamageModelComponent>
+  <WalletComponent money="%d"></WalletComponent>
+  %s
+</Entity>`
+	perk := `<Entity tags="perk_entity"><UIIconComponent name="$perk_%s" is_perk="1"></UIIconComponent></Entity>`
+	saves := map[string]map[string]string{
+		filepath.Join(dst, "2024-06-12-17-49-18"): {
+			playerFileName: fmt.Sprintf(player, 100, 50, fmt.Sprintf(perk, "extra_perk")),
+			"same.txt":     "same",
+			"changed.txt":  "old",
+			"removed.txt":  "removed",
+			"persistent/flags/card_unlocked_black_hole": "",
+		},
+		src: {
+			playerFileName: fmt.Sprintf(player, 3600, 250, fmt.Sprintf(perk, "extra_perk")+fmt.Sprintf(perk, "vampirism")),
+			"same.txt":     "same",
+			"changed.txt":  "new",
+			"added.txt":    "added",
+			"persistent/flags/card_unlocked_black_hole":    "",
+			"persistent/flags/card_unlocked_nuke":          "",
+			"stats/sessions/2024-06-13-09-30-00_stats.xml": `<Stats dead="1"></Stats>`,
+		},
+	}
+	for dir, files := range saves {
+		paths := make(map[string]string, len(files))
+		for name, content := range files {
+			paths[filepath.Join(dir, filepath.FromSlash(name))] = content
+		}
+		writeFiles(t, paths)
+	}
+	// same.txt only differs in its modification time and changed.txt only in its content,
+	// both have to be compared by content
+	past := time.Now().Add(-time.Hour)
+	for path, modified := range map[string]time.Time{
+		filepath.Join(src, "same.txt"):                           past,
+		filepath.Join(src, "changed.txt"):                        past,
+		filepath.Join(dst, "2024-06-12-17-49-18", "changed.txt"): past,
+	} {
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diff, err := DiffSaves(dst, src, StrLatest, StrLive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	for _, file := range diff.Files {
+		files = append(files, fmt.Sprintf("%s %s %d %d", file.Change, file.Path, file.OldSize, file.NewSize))
+	}
+	playerSize := func(dir string) int64 {
+		info, err := os.Stat(filepath.Join(dir, playerFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+	expectedFiles := []string{
+		"added added.txt 0 5",
+		"changed changed.txt 3 3",
+		"added persistent/flags/card_unlocked_nuke 0 0",
+		fmt.Sprintf("changed %s %d %d", playerFileName, playerSize(filepath.Join(dst, "2024-06-12-17-49-18")), playerSize(src)),
+		"removed removed.txt 7 0",
+		"added stats/sessions/2024-06-13-09-30-00_stats.xml 0 24",
+	}
+	if !reflect.DeepEqual(files, expectedFiles) {
+		t.Errorf("DiffSaves() files = %v, expected %v", files, expectedFiles)
+	}
+	if diff.From.ID != "2024-06-12-17-49-18" || diff.To.ID != StrLive {
+		t.Errorf("DiffSaves() compared %s to %s", diff.From.ID, diff.To.ID)
+	}
+	if diff.From.Metadata.Gold != 50 || diff.To.Metadata.Gold != 250 || diff.To.Metadata.Biome != "Snowy Depths" {
+		t.Errorf("DiffSaves() metadata %+v -> %+v", diff.From.Metadata, diff.To.Metadata)
+	}
+	if !reflect.DeepEqual(diff.PerksGained, []string{"VAMPIRISM"}) || len(diff.PerksLost) != 0 {
+		t.Errorf("DiffSaves() perks gained %v and lost %v, expected VAMPIRISM", diff.PerksGained, diff.PerksLost)
+	}
+	if !reflect.DeepEqual(diff.Unlocks.Gained, map[string][]string{"spells": {"card_unlocked_nuke"}}) {
+		t.Errorf("DiffSaves() unlocks gained %v, expected card_unlocked_nuke", diff.Unlocks.Gained)
+	}
+	if diff.From.Sessions != 0 || diff.To.Sessions != 1 {
+		t.Errorf("DiffSaves() sessions %d -> %d, expected 0 -> 1", diff.From.Sessions, diff.To.Sessions)
+	}
+}
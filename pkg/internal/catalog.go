@@ -0,0 +1,317 @@
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackupInfo describes a single backup as shown by the list command.
+type BackupInfo struct {
+	ID          string        `json:"id"`
+	Timestamp   time.Time     `json:"timestamp"`
+	Path        string        `json:"path"`
+	Format      string        `json:"format"`
+	LogicalSize int64         `json:"logical_size"`
+	DiskSize    int64         `json:"disk_size"`
+	Files       int           `json:"files"`
+	Pinned      bool          `json:"pinned"`
+	Version     string        `json:"version,omitempty"`
+	Duration    string        `json:"duration,omitempty"`
+	Metadata    *SaveMetadata `json:"metadata,omitempty"`
+}
+
+// ListFilter narrows down the backups returned by ListBackups, zero values disable a filter.
+type ListFilter struct {
+	Since    time.Time
+	Before   time.Time
+	Limit    int
+	Metadata []MetadataFilter
+}
+
+// fileKey identifies a file on disk, hard links to the same file share a key.
+type fileKey struct {
+	volume uint64
+	index  uint64
+}
+
+// MetadataFilter matches backups by the save they hold, see ParseMetadataFilter.
+type MetadataFilter struct {
+	Key   string
+	Value string
+}
+
+const (
+	FilterPerk        = "perk"
+	FilterBiome       = "biome"
+	FilterOrbs        = "orbs"
+	FilterNewGamePlus = "ng"
+)
+
+// ParseMetadataFilter parses key=value where key is one of perk, biome, orbs or ng,
+// for example perk=EXTRA_PERK or biome="Snowy Depths".
+func ParseMetadataFilter(value string) (MetadataFilter, error) {
+	key, v, ok := strings.Cut(value, "=")
+	filter := MetadataFilter{Key: strings.ToLower(strings.TrimSpace(key)), Value: strings.TrimSpace(v)}
+	if !ok || filter.Value == "" {
+		return filter, fmt.Errorf(ErrInvalidFilter, value)
+	}
+
+	switch filter.Key {
+	case FilterPerk, FilterBiome:
+	case FilterOrbs, FilterNewGamePlus:
+		if _, err := strconv.Atoi(filter.Value); err != nil {
+			return filter, fmt.Errorf(ErrInvalidFilter, value)
+		}
+	default:
+		return filter, fmt.Errorf(ErrInvalidFilter, value)
+	}
+
+	return filter, nil
+}
+
+// Match reports whether the save described by m matches the filter, backups without
+// metadata never match.
+func (f MetadataFilter) Match(m *SaveMetadata) bool {
+	if m == nil {
+		return false
+	}
+
+	switch f.Key {
+	case FilterPerk:
+		return m.HasPerk(f.Value)
+	case FilterBiome:
+		return strings.EqualFold(m.Biome, f.Value)
+	case FilterOrbs:
+		return strconv.Itoa(m.Orbs) == f.Value
+	case FilterNewGamePlus:
+		return strconv.Itoa(m.NewGamePlus) == f.Value
+	}
+
+	return false
+}
+
+// ListBackups describes every backup in backupPath matching filter, newest first.
+func ListBackups(backupPath string, filter ListFilter) ([]BackupInfo, error) {
+	backupDirs, err := getBackupDirs(backupPath, TimeFormat)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrFailedGettingBackupDirs, err)
+	}
+
+	sizes, err := diskSizes(backupPath, backupDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []BackupInfo
+	for i := len(backupDirs) - 1; i >= 0; i-- {
+		timestamp := localTime(backupDirs[i])
+		if !filter.Since.IsZero() && timestamp.Before(filter.Since) {
+			continue
+		}
+		if !filter.Before.IsZero() && !timestamp.Before(filter.Before) {
+			continue
+		}
+
+		info, err := describeBackup(backupPath, backupDirs[i])
+		if err != nil {
+			return nil, err
+		}
+		if !matchesMetadata(info.Metadata, filter.Metadata) {
+			continue
+		}
+		info.DiskSize = sizes[backupDirs[i]]
+		infos = append(infos, info)
+
+		if filter.Limit > 0 && len(infos) >= filter.Limit {
+			break
+		}
+	}
+
+	return infos, nil
+}
+
+func matchesMetadata(m *SaveMetadata, filters []MetadataFilter) bool {
+	for _, filter := range filters {
+		if !filter.Match(m) {
+			return false
+		}
+	}
+	return true
+}
+
+func describeBackup(backupPath string, backupDir time.Time) (BackupInfo, error) {
+	info := BackupInfo{
+		ID:        backupDir.Format(TimeFormat),
+		Timestamp: localTime(backupDir),
+		Path:      getBackupPath(backupPath, backupDir),
+		Pinned:    isPinned(backupPath, backupDir),
+	}
+
+	reader, err := openBackup(info.Path)
+	if err != nil {
+		return info, fmt.Errorf("%s: %v", ErrOpeningBackup, err)
+	}
+	defer func() { _ = reader.Close() }()
+	info.Format = reader.format
+
+	entries, err := reader.Entries()
+	if err != nil {
+		return info, fmt.Errorf("%s: %v", ErrOpeningBackup, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			info.Files++
+		}
+	}
+
+	// the logical size is that of the files a backup holds, whatever compression, hard
+	// links or shared objects save on disk
+	for _, entry := range entries {
+		info.LogicalSize += entry.Size
+	}
+
+	if m, err := reader.Manifest(); err == nil {
+		info.Version = m.Version
+		info.Duration = m.Duration
+		info.Metadata = m.Metadata
+	}
+
+	return info, nil
+}
+
+// diskSizes returns the bytes each backup takes on disk on top of the backups before it.
+// Files hard linked between directory backups and objects shared between snapshots are
+// counted once, for the oldest backup holding them.
+func diskSizes(backupPath string, backupDirs []time.Time) (map[time.Time]int64, error) {
+	sizes := make(map[time.Time]int64, len(backupDirs))
+	files := make(map[fileKey]bool)
+	objects := make(map[string]bool)
+
+	for _, backupDir := range backupDirs {
+		size, err := diskSize(getBackupPath(backupPath, backupDir), files, objects)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", ErrOpeningBackup, err)
+		}
+		sizes[backupDir] = size
+	}
+
+	return sizes, nil
+}
+
+// diskSize returns the bytes the backup at path takes on disk, leaving out the files and
+// objects already counted and adding its own to them.
+func diskSize(path string, files map[fileKey]bool, objects map[string]bool) (int64, error) {
+	var size int64
+
+	switch filepath.Ext(path) {
+	case zipExtension:
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		size = info.Size()
+	case snapshotExtension:
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		size = info.Size()
+
+		m, err := readManifest(path)
+		if err != nil {
+			return 0, err
+		}
+		store := filepath.Join(filepath.Dir(path), repoObjectsDir)
+		for _, entry := range m.Entries {
+			if entry.IsDir() || objects[entry.SHA256] {
+				continue
+			}
+			objects[entry.SHA256] = true
+
+			// missing objects are reported by verify
+			if info, err := os.Stat(objectPath(store, entry.SHA256)); err == nil {
+				size += info.Size()
+			}
+		}
+	default:
+		err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if key, ok := fileID(path, info); ok {
+				if files[key] {
+					return nil
+				}
+				files[key] = true
+			}
+			size += info.Size()
+
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return size, nil
+}
+
+// ParseTimeFilter parses a point in time given either as a duration before now, such
+// as 24h, or as a backup timestamp or prefix of one, such as 2024-06-12.
+func ParseTimeFilter(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	for _, layout := range []string{TimeFormat, "2006-01-02-15-04", "2006-01-02-15", "2006-01-02", "2006-01"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf(ErrInvalidTimeFilter, value)
+}
+
+// localTime interprets a timestamp parsed from a backup name, which carries the wall
+// clock of the machine taking the backup, in the local time zone.
+func localTime(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+}
+
+// FormatSize formats a number of bytes for humans.
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// FormatAge formats the time passed since t for humans.
+func FormatAge(t time.Time) string {
+	d := time.Since(t).Round(time.Minute)
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
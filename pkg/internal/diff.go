@@ -0,0 +1,277 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	FileAdded   = "added"
+	FileRemoved = "removed"
+	FileChanged = "changed"
+)
+
+// FileChange describes a file that differs between two saves.
+type FileChange struct {
+	Path    string `json:"path"`
+	Change  string `json:"change"`
+	OldSize int64  `json:"old_size"`
+	NewSize int64  `json:"new_size"`
+}
+
+// SaveState summarises one side of a Diff.
+type SaveState struct {
+	ID       string        `json:"id"`
+	Metadata *SaveMetadata `json:"metadata,omitempty"`
+	Wands    []string      `json:"wands"`
+	Sessions int           `json:"sessions"`
+	Unlocks  int           `json:"unlocks"`
+}
+
+// Diff compares two saves file by file and by the state of the run they hold.
+type Diff struct {
+	From        SaveState     `json:"from"`
+	To          SaveState     `json:"to"`
+	Files       []FileChange  `json:"files"`
+	PerksGained []string      `json:"perks_gained"`
+	PerksLost   []string      `json:"perks_lost"`
+	WandsGained []string      `json:"wands_gained"`
+	WandsLost   []string      `json:"wands_lost"`
+	Unlocks     *ProgressDiff `json:"unlocks"`
+}
+
+// CountFiles counts the file changes of the given kind.
+func (d *Diff) CountFiles(change string) int {
+	return countFiles(d.Files, change)
+}
+
+func countFiles(files []FileChange, change string) int {
+	n := 0
+	for _, file := range files {
+		if file.Change == change {
+			n++
+		}
+	}
+	return n
+}
+
+// DiffSaves compares the saves selected by from and to.  Each is either a backup id
+// as understood by findBackup or live for the save00 at srcPath.
+func DiffSaves(backupPath, srcPath, from, to string) (*Diff, error) {
+	a, err := openSave(backupPath, srcPath, from)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = a.reader.Close() }()
+
+	b, err := openSave(backupPath, srcPath, to)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = b.reader.Close() }()
+
+	diff := &Diff{}
+	if diff.Files, err = diffFiles(a.reader, b.reader); err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrDiffFailed, err)
+	}
+
+	var progress [2]*Progress
+	for i, side := range []*savedState{a, b} {
+		state, p, err := side.summarise()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", ErrDiffFailed, err)
+		}
+		if i == 0 {
+			diff.From = state
+		} else {
+			diff.To = state
+		}
+		progress[i] = p
+	}
+
+	var fromPerks, toPerks []string
+	if diff.From.Metadata != nil {
+		fromPerks = diff.From.Metadata.Perks
+	}
+	if diff.To.Metadata != nil {
+		toPerks = diff.To.Metadata.Perks
+	}
+	diff.PerksGained, diff.PerksLost = subtractStrings(toPerks, fromPerks), subtractStrings(fromPerks, toPerks)
+	diff.WandsGained, diff.WandsLost = subtractStrings(diff.To.Wands, diff.From.Wands), subtractStrings(diff.From.Wands, diff.To.Wands)
+	diff.Unlocks = diffProgress(progress[0], progress[1])
+
+	return diff, nil
+}
+
+type savedState struct {
+	id     string
+	reader *backupReader
+}
+
+func openSave(backupPath, srcPath, id string) (*savedState, error) {
+	if id == StrLive {
+		reader, err := openBackup(srcPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", ErrOpeningBackup, err)
+		}
+		return &savedState{id: StrLive, reader: reader}, nil
+	}
+
+	timestamp, err := findBackupByID(backupPath, id)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := openBackup(getBackupPath(backupPath, timestamp))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrOpeningBackup, err)
+	}
+
+	return &savedState{id: timestamp.Format(TimeFormat), reader: reader}, nil
+}
+
+func (s *savedState) summarise() (SaveState, *Progress, error) {
+	state := SaveState{ID: s.id, Wands: []string{}}
+
+	var err error
+	if state.Metadata, err = inspectReader(s.reader); err != nil {
+		return state, nil, err
+	}
+
+	if player, err := s.reader.Open(playerFileName); err == nil {
+		inventory, err := inspectInventory(player)
+		_ = player.Close()
+		if err != nil {
+			return state, nil, err
+		}
+		for _, wand := range inventory.Wands {
+			state.Wands = append(state.Wands, fmt.Sprintf("%s [%s]", wand.Name, strings.Join(wand.Spells, ", ")))
+		}
+	}
+
+	progress, err := readProgress(s.reader)
+	if err != nil {
+		return state, nil, err
+	}
+	progress.ID = s.id
+	state.Unlocks = progress.Total()
+
+	entries, err := s.reader.Entries()
+	if err != nil {
+		return state, nil, err
+	}
+	for _, entry := range entries {
+		if isSessionStats(entry.Path) {
+			state.Sessions++
+		}
+	}
+
+	return state, progress, nil
+}
+
+// diffFiles lists the files added, removed and changed going from a to b.  Files of
+// the same size are compared by the hash their manifest recorded, or by their contents
+// when there is none, as a rewrite keeping size and modification time is still a
+// change.  A nil reader stands for an empty save.
+func diffFiles(a, b *backupReader) ([]FileChange, error) {
+	aFiles, err := fileEntries(a)
+	if err != nil {
+		return nil, err
+	}
+	bFiles, err := fileEntries(b)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]FileChange, 0)
+	for p, old := range aFiles {
+		current, ok := bFiles[p]
+		if !ok {
+			changes = append(changes, FileChange{Path: p, Change: FileRemoved, OldSize: old.Size})
+			continue
+		}
+		changed := old.Size != current.Size
+		if !changed {
+			oldHash, err := entryHash(a, old)
+			if err != nil {
+				return nil, err
+			}
+			newHash, err := entryHash(b, current)
+			if err != nil {
+				return nil, err
+			}
+			changed = oldHash != newHash
+		}
+		if changed {
+			changes = append(changes, FileChange{Path: p, Change: FileChanged, OldSize: old.Size, NewSize: current.Size})
+		}
+	}
+	for p, current := range bFiles {
+		if _, ok := aFiles[p]; !ok {
+			changes = append(changes, FileChange{Path: p, Change: FileAdded, NewSize: current.Size})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+
+	return changes, nil
+}
+
+// fileEntries returns the files of a backup by path, along with the hash its manifest
+// recorded for every file that still has the recorded size and modification time.
+func fileEntries(r *backupReader) (map[string]ManifestEntry, error) {
+	if r == nil {
+		return map[string]ManifestEntry{}, nil
+	}
+
+	entries, err := r.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := make(map[string]ManifestEntry)
+	if m, err := r.Manifest(); err == nil {
+		for _, entry := range m.Entries {
+			recorded[entry.Path] = entry
+		}
+	}
+
+	files := make(map[string]ManifestEntry, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if previous, ok := recorded[entry.Path]; ok && previous.Size == entry.Size && previous.ModTime.Equal(entry.ModTime) {
+			entry.SHA256 = previous.SHA256
+		}
+		files[entry.Path] = entry
+	}
+	return files, nil
+}
+
+// entryHash returns the recorded hash of a file, or hashes its contents without one.
+func entryHash(r *backupReader, entry ManifestEntry) (string, error) {
+	if entry.SHA256 != "" {
+		return entry.SHA256, nil
+	}
+
+	return hashBackupFile(r, entry.Path)
+}
+
+// subtractStrings returns the strings in a that are not in b, keeping repeats so a
+// perk picked twice shows up twice.
+func subtractStrings(a, b []string) []string {
+	count := make(map[string]int, len(b))
+	for _, s := range b {
+		count[s]++
+	}
+
+	diff := make([]string, 0)
+	for _, s := range a {
+		if count[s] > 0 {
+			count[s]--
+			continue
+		}
+		diff = append(diff, s)
+	}
+	return diff
+}
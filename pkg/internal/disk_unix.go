@@ -0,0 +1,18 @@
+//go:build unix
+
+package internal
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID identifies a file by its device and inode number.
+func fileID(_ string, info fs.FileInfo) (fileKey, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, false
+	}
+
+	return fileKey{volume: uint64(stat.Dev), index: uint64(stat.Ino)}, true
+}
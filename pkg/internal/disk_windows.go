@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"golang.org/x/sys/windows"
+	"io/fs"
+	"os"
+)
+
+// fileID identifies a file by its volume serial number and file index, the file has to
+// be opened as neither is part of the info returned while walking a directory.
+func fileID(path string, _ fs.FileInfo) (fileKey, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileKey{}, false
+	}
+	defer func() { _ = f.Close() }()
+
+	var data windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(windows.Handle(f.Fd()), &data); err != nil {
+		return fileKey{}, false
+	}
+
+	return fileKey{
+		volume: uint64(data.VolumeSerialNumber),
+		index:  uint64(data.FileIndexHigh)<<32 | uint64(data.FileIndexLow),
+	}, true
+}
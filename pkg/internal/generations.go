@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	undoSuffix = ".undo"
+)
+
+// generationPath returns the path of the nth emergency generation of srcPath, save00.bak.1
+// being the save that was replaced by the most recent restore.
+func generationPath(srcPath string, n int) string {
+	return fmt.Sprintf("%s%s.%d", srcPath, backupSuffix, n)
+}
+
+// rotateGenerations moves srcPath to the first generation after shifting the existing
+// generations up by one.  Generations beyond the configured number are removed and a
+// save00.bak left by older versions becomes a generation first.
+func rotateGenerations(logRing *LogRing, srcPath string, generations int) error {
+	if generations <= 0 {
+		generations = 1
+	}
+
+	if legacy := srcPath + backupSuffix; exists(legacy) {
+		logRing.LogAndAppend(fmt.Sprintf(InfoMigratingBak, legacy))
+		if err := shiftGenerations(logRing, srcPath, generations); err != nil {
+			return err
+		}
+		if err := os.Rename(legacy, generationPath(srcPath, 1)); err != nil {
+			return err
+		}
+	}
+
+	if err := shiftGenerations(logRing, srcPath, generations); err != nil {
+		return err
+	}
+
+	logRing.LogAndAppend(fmt.Sprintf(InfoRename, generationPath(srcPath, 1)))
+	return os.Rename(srcPath, generationPath(srcPath, 1))
+}
+
+// shiftGenerations frees the first generation by removing the oldest kept generation,
+// along with any beyond it, and renaming every other generation n to n+1.
+func shiftGenerations(logRing *LogRing, srcPath string, generations int) error {
+	for n := generations; exists(generationPath(srcPath, n)); n++ {
+		if err := deletePath(logRing, generationPath(srcPath, n)); err != nil {
+			return err
+		}
+	}
+
+	for n := generations - 1; n >= 1; n-- {
+		if !exists(generationPath(srcPath, n)) {
+			continue
+		}
+		if err := os.Rename(generationPath(srcPath, n), generationPath(srcPath, n+1)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compactGenerations renumbers the generations left by an interrupted shift so they
+// follow each other again from save00.bak.1.
+func compactGenerations(srcPath string, generations int) error {
+	if generations <= 0 {
+		generations = 1
+	}
+
+	next := 1
+	for n := 1; n <= generations+1; n++ {
+		if !exists(generationPath(srcPath, n)) {
+			continue
+		}
+		if n != next {
+			if err := os.Rename(generationPath(srcPath, n), generationPath(srcPath, next)); err != nil {
+				return err
+			}
+		}
+		next++
+	}
+
+	return nil
+}
+
+// unrotateGenerations moves the first generation back to srcPath, which must no longer
+// exist, and shifts the remaining generations down by one.  The generation removed by
+// the rotation it reverts is gone for good.
+func unrotateGenerations(logRing *LogRing, srcPath string) error {
+	if !exists(generationPath(srcPath, 1)) {
+		return nil
+	}
+
+	logRing.LogAndAppend(fmt.Sprintf(InfoRenameRestore, generationPath(srcPath, 1)))
+	if err := os.Rename(generationPath(srcPath, 1), srcPath); err != nil {
+		return err
+	}
+
+	for n := 2; exists(generationPath(srcPath, n)); n++ {
+		if err := os.Rename(generationPath(srcPath, n), generationPath(srcPath, n-1)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UndoRestore swaps save00 with save00.bak.1, bringing back the save replaced by the last
+// restore.  Undoing again swaps them back.  An interrupted restore into srcPath, journaled
+// in dstPath, has to be rolled forward or back first.
+func UndoRestore(logRing *LogRing, srcPath, dstPath string, detector ProcessDetector) error {
+	if process, running := detector.NoitaProcess(); running {
+		return errors.New(noitaRunningError(process, ErrDuringUndo))
+	}
+
+	if journal, err := ReadRestoreJournal(dstPath); err != nil {
+		return err
+	} else if journal.Incomplete() {
+		return fmt.Errorf("%s: %s", ErrRestorePending, journal)
+	}
+
+	previous := generationPath(srcPath, 1)
+	if !exists(previous) {
+		return fmt.Errorf(ErrNoGeneration, previous)
+	}
+
+	if !exists(srcPath) {
+		return unrotateGenerations(logRing, srcPath)
+	}
+
+	// swap through a temporary name so neither save is ever missing
+	undo := srcPath + undoSuffix
+	if err := os.RemoveAll(undo); err != nil {
+		return err
+	}
+	if err := os.Rename(srcPath, undo); err != nil {
+		return err
+	}
+	if err := os.Rename(previous, srcPath); err != nil {
+		_ = os.Rename(undo, srcPath)
+		return err
+	}
+	if err := os.Rename(undo, previous); err != nil {
+		return err
+	}
+
+	logRing.LogAndAppend(fmt.Sprintf(InfoUndoRestore, srcPath, previous))
+	return nil
+}
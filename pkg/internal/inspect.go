@@ -0,0 +1,392 @@
+package internal
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	playerFileName     = "player.xml"
+	worldStateFileName = "world_state.xml"
+	perkIconPrefix     = "$perk_"
+	newGamePlusGlobal  = "NEW_GAME_PLUS_COUNT"
+	// hpScale converts the hp stored in player.xml into the hp shown in game
+	hpScale = 25
+)
+
+// SaveMetadata summarises the state of the player in a save.  NewGamePlus counts the
+// new game+ cycles, 0 is the first playthrough.
+type SaveMetadata struct {
+	HP          float64  `json:"hp"`
+	MaxHP       float64  `json:"max_hp"`
+	Gold        int      `json:"gold"`
+	X           float64  `json:"x"`
+	Y           float64  `json:"y"`
+	Biome       string   `json:"biome"`
+	Perks       []string `json:"perks,omitempty"`
+	Orbs        int      `json:"orbs"`
+	NewGamePlus int      `json:"new_game_plus"`
+}
+
+func (m *SaveMetadata) String() string {
+	if m == nil {
+		return ""
+	}
+	return fmt.Sprintf("HP %.0f/%.0f, %d gold, %s (%.0f, %.0f)", m.HP, m.MaxHP, m.Gold, m.Biome, m.X, m.Y)
+}
+
+// HasPerk reports whether the player picked up the perk with the given id, such as EXTRA_PERK.
+func (m *SaveMetadata) HasPerk(perk string) bool {
+	if m == nil {
+		return false
+	}
+	for _, p := range m.Perks {
+		if strings.EqualFold(p, perk) {
+			return true
+		}
+	}
+	return false
+}
+
+// biomeDepth is the lower edge of a biome along the main path down.
+type biomeDepth struct {
+	maxY float64
+	name string
+}
+
+// biomes along the main path, each followed by its Holy Mountain.  Side biomes share
+// depths with these and are not told apart.
+var biomes = []biomeDepth{
+	{512, "Surface"},
+	{1536, "Mines"},
+	{3072, "Coal Pits"},
+	{5120, "Snowy Depths"},
+	{6656, "Hiisi Base"},
+	{8704, "Underground Jungle"},
+	{10752, "The Vault"},
+	{12800, "Temple of the Art"},
+	{math.Inf(1), "The Laboratory"},
+}
+
+// BiomeAt names the biome of the main path at depth y.
+func BiomeAt(y float64) string {
+	for _, biome := range biomes {
+		if y < biome.maxY {
+			return biome.name
+		}
+	}
+	return biomes[len(biomes)-1].name
+}
+
+// xmlNode is a generic element of the entity files Noita writes.
+type xmlNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []xmlNode  `xml:",any"`
+}
+
+func (n *xmlNode) attr(name string) string {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func (n *xmlNode) floatAttr(name string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(n.attr(name)), 64)
+	return f
+}
+
+// child returns the first direct child element called name.
+func (n *xmlNode) child(name string) *xmlNode {
+	for i := range n.Children {
+		if n.Children[i].XMLName.Local == name {
+			return &n.Children[i]
+		}
+	}
+	return nil
+}
+
+func parseXMLNode(r io.Reader) (*xmlNode, error) {
+	var root xmlNode
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// inspectSave reads the metadata of the save in savePath.  Noita removes player.xml
+// between runs, a save without a run in progress has no metadata.
+func inspectSave(savePath string) (*SaveMetadata, error) {
+	reader, err := openBackup(savePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrInspectingSave, err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	return inspectReader(reader)
+}
+
+// inspectReader reads the metadata of the save held by r, see inspectSave.
+func inspectReader(r *backupReader) (*SaveMetadata, error) {
+	player, err := r.Open(playerFileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrInspectingSave, err)
+	}
+	defer func() { _ = player.Close() }()
+
+	m, err := inspectPlayer(player)
+	if err != nil {
+		return nil, err
+	}
+
+	world, err := r.Open(worldStateFileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return m, fmt.Errorf("%s: %v", ErrInspectingSave, err)
+	}
+	defer func() { _ = world.Close() }()
+
+	return m, inspectWorldState(world, m)
+}
+
+// readBackupMetadata returns the save metadata recorded in the manifest of the
+// backup at path, backups taken before metadata was recorded have none.
+func readBackupMetadata(path string) *SaveMetadata {
+	reader, err := openBackup(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = reader.Close() }()
+
+	m, err := reader.Manifest()
+	if err != nil {
+		return nil
+	}
+
+	return m.Metadata
+}
+
+// inspectPlayer reads the player entity stored in save00/player.xml.
+func inspectPlayer(r io.Reader) (*SaveMetadata, error) {
+	player, err := parseXMLNode(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrInspectingSave, err)
+	}
+
+	m := &SaveMetadata{}
+	if damage := player.child("DamageModelComponent"); damage != nil {
+		m.HP = damage.floatAttr("hp") * hpScale
+		m.MaxHP = damage.floatAttr("max_hp") * hpScale
+	}
+	if wallet := player.child("WalletComponent"); wallet != nil {
+		m.Gold = int(wallet.floatAttr("money"))
+	}
+	if transform := player.child("_Transform"); transform != nil {
+		m.X = transform.floatAttr("position.x")
+		m.Y = transform.floatAttr("position.y")
+	}
+	m.Biome = BiomeAt(m.Y)
+
+	// every perk picked up adds a child entity showing its icon, effect perks carry a
+	// game effect as well
+	for i := range player.Children {
+		perk := &player.Children[i]
+		if perk.XMLName.Local != "Entity" {
+			continue
+		}
+		if icon := perk.child("UIIconComponent"); icon != nil && icon.attr("is_perk") == "1" {
+			m.Perks = append(m.Perks, strings.ToUpper(strings.TrimPrefix(icon.attr("name"), perkIconPrefix)))
+		} else if effect := perk.child("GameEffectComponent"); effect != nil && strings.Contains(perk.attr("tags"), "perk_entity") {
+			m.Perks = append(m.Perks, effect.attr("effect"))
+		}
+	}
+
+	return m, nil
+}
+
+// inspectWorldState reads the orbs found this run and the new game+ cycle from
+// save00/world_state.xml into m.
+func inspectWorldState(r io.Reader, m *SaveMetadata) error {
+	world, err := parseXMLNode(r)
+	if err != nil {
+		return fmt.Errorf("%s: %v", ErrInspectingSave, err)
+	}
+
+	state := world.child("WorldStateComponent")
+	if state == nil {
+		return nil
+	}
+	if orbs := state.child("orbs_found_thisrun"); orbs != nil {
+		m.Orbs = len(orbs.Children)
+	}
+	if globals := state.child("lua_globals"); globals != nil {
+		for _, global := range globals.Children {
+			if global.attr("key") == newGamePlusGlobal {
+				m.NewGamePlus, _ = strconv.Atoi(global.attr("value"))
+			}
+		}
+	}
+
+	return nil
+}
+
+// Wand describes a wand carried by the player, times are in seconds.
+type Wand struct {
+	Name            string   `json:"name"`
+	Capacity        int      `json:"capacity"`
+	SpellsPerCast   int      `json:"spells_per_cast"`
+	CastDelay       float64  `json:"cast_delay"`
+	Recharge        float64  `json:"recharge"`
+	Mana            float64  `json:"mana"`
+	ManaMax         float64  `json:"mana_max"`
+	ManaChargeSpeed float64  `json:"mana_charge_speed"`
+	Spread          float64  `json:"spread"`
+	Shuffle         bool     `json:"shuffle"`
+	Spells          []string `json:"spells"`
+}
+
+// Inventory holds the wands of the player along with the spells that are not on a wand.
+type Inventory struct {
+	Wands  []Wand   `json:"wands"`
+	Spells []string `json:"spells"`
+}
+
+// framesPerSecond converts the frame counts Noita stores for wands into seconds
+const framesPerSecond = 60
+
+// inspectInventory walks the inventories of the player entity in player.xml.  Wands
+// live in inventory_quick as entities with an AbilityComponent, spells are entities
+// with an ItemActionComponent either on a wand or loose in inventory_full.
+func inspectInventory(r io.Reader) (*Inventory, error) {
+	player, err := parseXMLNode(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrInspectingSave, err)
+	}
+
+	inventory := &Inventory{Wands: []Wand{}, Spells: []string{}}
+	for _, child := range player.Children {
+		if child.XMLName.Local != "Entity" {
+			continue
+		}
+		for i := range child.Children {
+			item := &child.Children[i]
+			if item.XMLName.Local != "Entity" {
+				continue
+			}
+			if ability := item.child("AbilityComponent"); ability != nil {
+				inventory.Wands = append(inventory.Wands, inspectWand(item, ability, len(inventory.Wands)+1))
+			} else if spell := spellName(item); spell != "" {
+				inventory.Spells = append(inventory.Spells, spell)
+			}
+		}
+	}
+
+	return inventory, nil
+}
+
+func inspectWand(wand, ability *xmlNode, number int) Wand {
+	w := Wand{
+		Name:            ability.attr("ui_name"),
+		Mana:            ability.floatAttr("mana"),
+		ManaMax:         ability.floatAttr("mana_max"),
+		ManaChargeSpeed: ability.floatAttr("mana_charge_speed"),
+		Spells:          []string{},
+	}
+	if w.Name == "" {
+		w.Name = fmt.Sprintf("wand %d", number)
+	}
+	if gun := ability.child("gun_config"); gun != nil {
+		w.Capacity = int(gun.floatAttr("deck_capacity"))
+		w.SpellsPerCast = int(gun.floatAttr("actions_per_round"))
+		w.Recharge = gun.floatAttr("reload_time") / framesPerSecond
+		w.Shuffle = gun.attr("shuffle_deck_when_empty") == "1"
+	}
+	if action := ability.child("gunaction_config"); action != nil {
+		w.CastDelay = action.floatAttr("fire_rate_wait") / framesPerSecond
+		w.Spread = action.floatAttr("spread_degrees")
+	}
+
+	// spells are listed in the order of their slots on the wand
+	type slotted struct {
+		slot  float64
+		spell string
+	}
+	var spells []slotted
+	for i := range wand.Children {
+		if spell := spellName(&wand.Children[i]); spell != "" {
+			slot := 0.0
+			if item := wand.Children[i].child("ItemComponent"); item != nil {
+				slot = item.floatAttr("inventory_slot.x")
+			}
+			spells = append(spells, slotted{slot, spell})
+		}
+	}
+	sort.SliceStable(spells, func(i, j int) bool { return spells[i].slot < spells[j].slot })
+	for _, s := range spells {
+		w.Spells = append(w.Spells, s.spell)
+	}
+
+	return w
+}
+
+// spellName returns the action id of a spell entity, or an empty string for anything else.
+func spellName(entity *xmlNode) string {
+	if entity.XMLName.Local != "Entity" {
+		return ""
+	}
+	if action := entity.child("ItemActionComponent"); action != nil {
+		return action.attr("action_id")
+	}
+	return ""
+}
+
+// Inspection is everything the save inspector reads from a backup.
+type Inspection struct {
+	ID        string        `json:"id"`
+	Metadata  *SaveMetadata `json:"metadata,omitempty"`
+	Inventory *Inventory    `json:"inventory,omitempty"`
+}
+
+// InspectBackup reads the player from the backup selected by id, see findBackup.
+func InspectBackup(backupPath, id string) (*Inspection, error) {
+	timestamp, err := findBackupByID(backupPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := openBackup(getBackupPath(backupPath, timestamp))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrOpeningBackup, err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	inspection := &Inspection{ID: timestamp.Format(TimeFormat)}
+	if m, err := reader.Manifest(); err == nil {
+		inspection.Metadata = m.Metadata
+	}
+
+	// a backup without a run in progress has no player.xml
+	f, err := reader.Open(playerFileName)
+	if err != nil {
+		return inspection, nil
+	}
+	defer func() { _ = f.Close() }()
+
+	inspection.Inventory, err = inspectInventory(f)
+	return inspection, err
+}
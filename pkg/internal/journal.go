@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	journalFileName = "restore.journal"
+	JournalRotating = "bak-rotating"
+	JournalRotated  = "bak-rotated"
+	JournalCopying  = "copy-started"
+	JournalComplete = "copy-complete"
+)
+
+// RestoreJournal records how far the last restore into save00 got, so a restore
+// interrupted by a crash can be finished or undone on the next start.
+type RestoreJournal struct {
+	Backup  string    `json:"backup"`
+	Source  string    `json:"source"`
+	Phase   string    `json:"phase"`
+	Updated time.Time `json:"updated"`
+}
+
+// Incomplete reports whether the restore stopped before the backup was copied in full.
+func (j *RestoreJournal) Incomplete() bool {
+	return j != nil && j.Phase != JournalComplete
+}
+
+func (j *RestoreJournal) String() string {
+	return fmt.Sprintf(InfoInterrupted, j.Backup, j.Source, j.Phase)
+}
+
+// ReadRestoreJournal returns the journal of the last restore into save00 from backupPath,
+// or nil when there is none.
+func ReadRestoreJournal(backupPath string) (*RestoreJournal, error) {
+	data, err := os.ReadFile(filepath.Join(backupPath, journalFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: %v", ErrReadingJournal, err)
+	}
+
+	var j RestoreJournal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrReadingJournal, err)
+	}
+
+	return &j, nil
+}
+
+// writeJournal moves the journal to phase and writes it to backupPath through a rename,
+// so a crash never leaves a half written journal behind.
+func writeJournal(backupPath string, j *RestoreJournal, phase string) error {
+	j.Phase = phase
+	j.Updated = time.Now()
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(backupPath, journalFileName)
+	tmp := path + tmpExtension
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func removeJournal(backupPath string) error {
+	if err := os.Remove(filepath.Join(backupPath, journalFileName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// RollForward finishes an interrupted restore by copying the journaled backup into a
+// fresh save00.
+func (j *RestoreJournal) RollForward(logRing *LogRing, backupPath string) error {
+	timestamp, err := time.Parse(TimeFormat, j.Backup)
+	if err != nil {
+		return fmt.Errorf("%s: %v", ErrRecoveringRestore, err)
+	}
+
+	// save00 left by an interrupted rotation is still the live save and is rotated first
+	if err := j.finishRotation(logRing); err != nil {
+		return fmt.Errorf("%s: %v", ErrRecoveringRestore, err)
+	}
+	if exists(j.Source) {
+		if err := deletePath(logRing, j.Source); err != nil {
+			return fmt.Errorf("%s: %v", ErrRecoveringRestore, err)
+		}
+	}
+
+	r := NewRestore(j.Backup, NewBackup(false, false, 1, j.Source, backupPath, nil))
+	r.Backup.LogRing = logRing
+	r.restoreTimestamp = timestamp
+	if err := writeJournal(backupPath, j, JournalCopying); err != nil {
+		return fmt.Errorf("%s: %v", ErrWritingJournal, err)
+	}
+	if err := r.extractBackup(j.Source); err != nil {
+		return fmt.Errorf("%s: %v", ErrRecoveringRestore, err)
+	}
+	if err := writeJournal(backupPath, j, JournalComplete); err != nil {
+		return fmt.Errorf("%s: %v", ErrWritingJournal, err)
+	}
+
+	logRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoRolledForward, j.Source))
+	return nil
+}
+
+// RollBack undoes an interrupted restore by removing the partial save00 and putting
+// save00.bak.1 back in its place.  A rotation interrupted before save00 was moved only
+// leaves the generations to renumber.
+func (j *RestoreJournal) RollBack(logRing *LogRing, backupPath string) error {
+	if j.rotationInterrupted() {
+		if err := compactGenerations(j.Source, viper.GetInt(ViperGenerations)); err != nil {
+			return fmt.Errorf("%s: %v", ErrRecoveringRestore, err)
+		}
+	} else {
+		if exists(j.Source) {
+			if err := deletePath(logRing, j.Source); err != nil {
+				return fmt.Errorf("%s: %v", ErrRecoveringRestore, err)
+			}
+		}
+		if err := unrotateGenerations(logRing, j.Source); err != nil {
+			return fmt.Errorf("%s: %v", ErrRecoveringRestore, err)
+		}
+	}
+	if err := removeJournal(backupPath); err != nil {
+		return fmt.Errorf("%s: %v", ErrWritingJournal, err)
+	}
+
+	logRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoRolledBack, j.Source))
+	return nil
+}
+
+// rotationInterrupted reports whether the restore stopped while rotating, before save00
+// was moved to save00.bak.1.
+func (j *RestoreJournal) rotationInterrupted() bool {
+	return j.Phase == JournalRotating && (exists(j.Source) || !exists(generationPath(j.Source, 1)))
+}
+
+// finishRotation completes a rotation interrupted before save00 was moved to save00.bak.1.
+func (j *RestoreJournal) finishRotation(logRing *LogRing) error {
+	if !j.rotationInterrupted() {
+		return nil
+	}
+
+	generations := viper.GetInt(ViperGenerations)
+	if err := compactGenerations(j.Source, generations); err != nil {
+		return err
+	}
+	if !exists(j.Source) {
+		return nil
+	}
+
+	return rotateGenerations(logRing, j.Source, generations)
+}
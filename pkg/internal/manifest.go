@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	manifestFileName = "manifest.json"
+)
+
+// Version of noitabackup recorded in every manifest, set at build time through
+// -ldflags "-X github.com/rgravlin/noitabackup/pkg/internal.Version=v1.2.3".
+var Version = "dev"
+
+// builds without the ldflag fall back to the module version or the commit they were built from
+func init() {
+	if Version != "dev" {
+		return
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		Version = info.Main.Version
+		return
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			Version = fmt.Sprintf("dev-%s", setting.Value[:min(len(setting.Value), 12)])
+			return
+		}
+	}
+}
+
+// Manifest describes every directory and file that makes up a backup together with
+// where and how the backup was taken.
+type Manifest struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Version   string          `json:"version"`
+	Format    string          `json:"format"`
+	Source    string          `json:"source"`
+	Workers   int             `json:"workers"`
+	Duration  string          `json:"duration"`
+	Dirs      int             `json:"dirs"`
+	Files     int             `json:"files"`
+	Metadata  *SaveMetadata   `json:"metadata,omitempty"`
+	Entries   []ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry describes a single directory or file relative to the backup root.
+type ManifestEntry struct {
+	Path    string      `json:"path"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mtime"`
+	SHA256  string      `json:"sha256,omitempty"`
+}
+
+func (e ManifestEntry) IsDir() bool {
+	return e.Mode.IsDir()
+}
+
+// hashManifestEntries fills in the SHA-256 of every file entry below root.
+func hashManifestEntries(root string, entries []ManifestEntry, numOfWorkers int) error {
+	return parallelFor(len(entries), numOfWorkers, func(i int) error {
+		if entries[i].IsDir() {
+			return nil
+		}
+
+		hash, err := hashFile(filepath.Join(root, filepath.FromSlash(entries[i].Path)))
+		if err != nil {
+			return fmt.Errorf("%s: %v", ErrHashingFile, err)
+		}
+		entries[i].SHA256 = hash
+
+		return nil
+	})
+}
+
+func readManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// writeManifest writes the manifest to a temporary file first and renames it into
+// place, so a manifest on disk is always complete.
+func writeManifest(path string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + tmpExtension
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	pinExtension = ".pin"
+)
+
+// PinBackup protects the backup matching id from rotation by writing a pin file next to it.
+func PinBackup(backupPath, id string) (string, error) {
+	timestamp, err := findBackupByID(backupPath, id)
+	if err != nil {
+		return "", err
+	}
+
+	pin := getPinPath(backupPath, timestamp)
+	if err := os.WriteFile(pin, []byte(time.Now().Format(TimeFormat)), 0644); err != nil {
+		return "", fmt.Errorf("%s: %v", ErrPinningBackup, err)
+	}
+
+	return timestamp.Format(TimeFormat), nil
+}
+
+// UnpinBackup removes the pin of the backup matching id so it rotates like any other.
+func UnpinBackup(backupPath, id string) (string, error) {
+	timestamp, err := findBackupByID(backupPath, id)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Remove(getPinPath(backupPath, timestamp)); err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("%s: %v", ErrPinningBackup, err)
+	}
+
+	return timestamp.Format(TimeFormat), nil
+}
+
+func isPinned(backupPath string, timestamp time.Time) bool {
+	return exists(getPinPath(backupPath, timestamp))
+}
+
+func getPinPath(backupPath string, timestamp time.Time) string {
+	return filepath.Join(backupPath, timestamp.Format(TimeFormat)+pinExtension)
+}
+
+// splitPinned separates pinned backups from those that rotate, keeping their order.
+func splitPinned(backupPath string, backupDirs []time.Time) (pinned, unpinned []time.Time) {
+	for _, backupDir := range backupDirs {
+		if isPinned(backupPath, backupDir) {
+			pinned = append(pinned, backupDir)
+		} else {
+			unpinned = append(unpinned, backupDir)
+		}
+	}
+
+	return pinned, unpinned
+}
@@ -1,48 +1,35 @@
 package internal
 
 import (
-	"golang.org/x/sys/windows"
-	"os"
-	"syscall"
+	"fmt"
+	"time"
 )
 
 const (
-	processEntrySize = 568
 	noitaProcessName = "noita.exe"
 )
 
-func processID(name string) (uint32, error) {
-	h, e := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
-	if e != nil {
-		return 0, e
-	}
-	p := windows.ProcessEntry32{Size: processEntrySize}
-	for {
-		e := windows.Process32Next(h, &p)
-		if e != nil {
-			return 0, e
-		}
-		if windows.UTF16ToString(p.ExeFile[:]) == name {
-			return p.ProcessID, nil
-		}
-	}
+// Process describes a running Noita process.
+type Process struct {
+	PID       int
+	StartTime time.Time
 }
 
-func isNoitaRunning() bool {
-	pid, err := processID(noitaProcessName)
-	if err != nil {
-		return false
+func (p Process) String() string {
+	if p.StartTime.IsZero() {
+		return fmt.Sprintf("pid %d", p.PID)
 	}
+	return fmt.Sprintf("pid %d, started %s", p.PID, p.StartTime.Format(LogRingTimeFormat))
+}
 
-	process, err := os.FindProcess(int(pid))
-	if err != nil {
-		return false
-	} else {
-		err := process.Signal(syscall.Signal(0))
-		if err != nil {
-			return true
-		} else {
-			return false
-		}
-	}
+// ProcessDetector finds the running Noita process.  NewProcessDetector returns the
+// implementation for the current platform.
+type ProcessDetector interface {
+	// NoitaProcess returns the running Noita process, running is false when there is none.
+	NoitaProcess() (process Process, running bool)
+}
+
+// noitaRunningError describes why an operation was refused because Noita is running.
+func noitaRunningError(process Process, during string) string {
+	return fmt.Sprintf("%s %s (%s)", ErrNoitaRunning, during, process)
 }
@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	procPath = "/proc"
+	// clockTicks is USER_HZ, the unit of process start times in /proc, which is 100 on
+	// every architecture Linux supports
+	clockTicks = 100
+)
+
+type linuxProcessDetector struct{}
+
+// NewProcessDetector returns a ProcessDetector scanning /proc.
+func NewProcessDetector() ProcessDetector {
+	return linuxProcessDetector{}
+}
+
+func (linuxProcessDetector) NoitaProcess() (Process, bool) {
+	pid, err := processID(noitaProcessName)
+	if err != nil {
+		return Process{}, false
+	}
+
+	return Process{PID: pid, StartTime: processStartTime(pid)}, true
+}
+
+// processID finds a process by executable name.  Under Proton the game runs as
+// noita.exe through wine, so both the command name and the first argument of the
+// command line, which holds the Windows path to the executable, are checked.
+func processID(name string) (int, error) {
+	entries, err := os.ReadDir(procPath)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() || processExited(pid) {
+			continue
+		}
+
+		comm, err := os.ReadFile(filepath.Join(procPath, entry.Name(), "comm"))
+		if err == nil && strings.EqualFold(strings.TrimSpace(string(comm)), name) {
+			return pid, nil
+		}
+
+		cmdline, err := os.ReadFile(filepath.Join(procPath, entry.Name(), "cmdline"))
+		if err != nil || len(cmdline) == 0 {
+			continue
+		}
+		exe := string(bytes.SplitN(cmdline, []byte{0}, 2)[0])
+		exe = exe[strings.LastIndexAny(exe, "/\\")+1:]
+		if strings.EqualFold(exe, name) {
+			return pid, nil
+		}
+	}
+
+	return 0, os.ErrNotExist
+}
+
+// processExited reports whether a process has exited and is only waiting to be reaped
+// by its parent, a zombie keeps its name but is no longer running.
+func processExited(pid int) bool {
+	stat, err := os.ReadFile(filepath.Join(procPath, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return true
+	}
+
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) == 0 || fields[0] == "Z" || fields[0] == "X"
+}
+
+// processStartTime returns the start time of a process, or the zero time when it
+// cannot be read.  /proc/<pid>/stat records it in clock ticks since boot.
+func processStartTime(pid int) time.Time {
+	stat, err := os.ReadFile(filepath.Join(procPath, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return time.Time{}
+	}
+
+	// the command name may contain spaces and parentheses, fields are counted after it
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	if len(fields) < 20 {
+		return time.Time{}
+	}
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	bootTime, err := readBootTime()
+	if err != nil {
+		return time.Time{}
+	}
+
+	return bootTime.Add(time.Duration(ticks) * time.Second / clockTicks)
+}
+
+func readBootTime() (time.Time, error) {
+	stat, err := os.ReadFile(filepath.Join(procPath, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, line := range strings.Split(string(stat), "\n") {
+		if value, ok := strings.CutPrefix(line, "btime "); ok {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+
+	return time.Time{}, os.ErrNotExist
+}
@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProcessID(t *testing.T) {
+	pid, err := processID(filepath.Base(os.Args[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid != os.Getpid() {
+		t.Errorf("processID() = %d, expected %d", pid, os.Getpid())
+	}
+
+	if _, err := processID("not-a-process.exe"); err == nil {
+		t.Error("processID() expected an error for a process that is not running")
+	}
+}
+
+func TestProcessStartTime(t *testing.T) {
+	started := processStartTime(os.Getpid())
+	if started.IsZero() || started.After(time.Now()) || time.Since(started) > time.Hour {
+		t.Errorf("processStartTime() = %v, expected the start of this test run", started)
+	}
+}
+
+func TestProcessExited(t *testing.T) {
+	if processExited(os.Getpid()) {
+		t.Error("processExited() reported the running test process as exited")
+	}
+
+	// a child that exited but was not waited for yet is a zombie
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), TestSteamEnv+"=1")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = cmd.Wait() }()
+	deadline := time.Now().Add(5 * time.Second)
+	for !processExited(cmd.Process.Pid) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !processExited(cmd.Process.Pid) {
+		t.Error("processExited() reported a zombie as running")
+	}
+}
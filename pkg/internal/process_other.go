@@ -0,0 +1,15 @@
+//go:build !windows && !linux
+
+package internal
+
+type otherProcessDetector struct{}
+
+// NewProcessDetector returns a ProcessDetector that cannot detect Noita on this
+// platform and assumes it is not running.
+func NewProcessDetector() ProcessDetector {
+	return otherProcessDetector{}
+}
+
+func (otherProcessDetector) NoitaProcess() (Process, bool) {
+	return Process{}, false
+}
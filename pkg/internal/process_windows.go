@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"golang.org/x/sys/windows"
+	"time"
+)
+
+const (
+	processEntrySize = 568
+)
+
+type windowsProcessDetector struct{}
+
+// NewProcessDetector returns a ProcessDetector walking a Toolhelp32 process snapshot.
+func NewProcessDetector() ProcessDetector {
+	return windowsProcessDetector{}
+}
+
+func (windowsProcessDetector) NoitaProcess() (Process, bool) {
+	pid, err := processID(noitaProcessName)
+	if err != nil {
+		return Process{}, false
+	}
+
+	return Process{PID: int(pid), StartTime: processStartTime(pid)}, true
+}
+
+func processID(name string) (uint32, error) {
+	h, e := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if e != nil {
+		return 0, e
+	}
+	defer func() { _ = windows.CloseHandle(h) }()
+
+	p := windows.ProcessEntry32{Size: processEntrySize}
+	for {
+		e := windows.Process32Next(h, &p)
+		if e != nil {
+			return 0, e
+		}
+		if windows.UTF16ToString(p.ExeFile[:]) == name {
+			return p.ProcessID, nil
+		}
+	}
+}
+
+// processStartTime returns the creation time of a process, or the zero time when
+// it cannot be queried.
+func processStartTime(pid uint32) time.Time {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return time.Time{}
+	}
+	defer func() { _ = windows.CloseHandle(h) }()
+
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(0, creation.Nanoseconds())
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	flagsDir = "persistent/flags"
+)
+
+// flagCategories maps the prefix of a file in persistent/flags to the kind of unlock
+// it records, flags matching none of them are counted as other.
+var flagCategories = []struct {
+	prefix   string
+	category string
+}{
+	{"card_unlocked_", "spells"},
+	{"perk_picked_", "perks"},
+	{"secret_", "secrets"},
+	{"progress_", "progress"},
+	{"essence_", "essences"},
+	{"miniboss_", "bosses"},
+	{"boss_", "bosses"},
+}
+
+const otherFlags = "other"
+
+// Progress holds the unlock flags of a save grouped by category.
+type Progress struct {
+	ID    string              `json:"id"`
+	Flags map[string][]string `json:"flags"`
+}
+
+// Total counts every unlock flag.
+func (p *Progress) Total() int {
+	total := 0
+	for _, flags := range p.Flags {
+		total += len(flags)
+	}
+	return total
+}
+
+// Categories returns the categories holding at least one flag in a stable order.
+func (p *Progress) Categories() []string {
+	return sortedCategories(p.Flags)
+}
+
+// ProgressDiff lists the unlock flags gained and lost between two saves.
+type ProgressDiff struct {
+	From   string              `json:"from"`
+	To     string              `json:"to"`
+	Gained map[string][]string `json:"gained"`
+	Lost   map[string][]string `json:"lost"`
+}
+
+func flagCategory(flag string) string {
+	for _, c := range flagCategories {
+		if strings.HasPrefix(flag, c.prefix) {
+			return c.category
+		}
+	}
+	return otherFlags
+}
+
+func sortedCategories(flags map[string][]string) []string {
+	var categories []string
+	for category, names := range flags {
+		if len(names) > 0 {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// readProgress collects the unlock flags of the backup or save read by r.
+func readProgress(r *backupReader) (*Progress, error) {
+	entries, err := r.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &Progress{Flags: make(map[string][]string)}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Dir(entry.Path) != flagsDir {
+			continue
+		}
+		flag := path.Base(entry.Path)
+		category := flagCategory(flag)
+		progress.Flags[category] = append(progress.Flags[category], flag)
+	}
+	for _, flags := range progress.Flags {
+		sort.Strings(flags)
+	}
+
+	return progress, nil
+}
+
+// BackupProgress reads the unlock flags of the backup selected by id, see findBackup.
+func BackupProgress(backupPath, id string) (*Progress, error) {
+	timestamp, err := findBackupByID(backupPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := openBackup(getBackupPath(backupPath, timestamp))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrOpeningBackup, err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	progress, err := readProgress(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrReadingProgress, err)
+	}
+	progress.ID = timestamp.Format(TimeFormat)
+
+	return progress, nil
+}
+
+// DiffProgress compares the unlock flags of the backups selected by from and to.
+func DiffProgress(backupPath, from, to string) (*ProgressDiff, error) {
+	a, err := BackupProgress(backupPath, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := BackupProgress(backupPath, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffProgress(a, b), nil
+}
+
+func diffProgress(a, b *Progress) *ProgressDiff {
+	return &ProgressDiff{
+		From:   a.ID,
+		To:     b.ID,
+		Gained: subtractFlags(b.Flags, a.Flags),
+		Lost:   subtractFlags(a.Flags, b.Flags),
+	}
+}
+
+// subtractFlags returns the flags in a that are not in b.
+func subtractFlags(a, b map[string][]string) map[string][]string {
+	diff := make(map[string][]string)
+	for category, flags := range a {
+		have := make(map[string]bool, len(b[category]))
+		for _, flag := range b[category] {
+			have[flag] = true
+		}
+		for _, flag := range flags {
+			if !have[flag] {
+				diff[category] = append(diff[category], flag)
+			}
+		}
+	}
+	return diff
+}
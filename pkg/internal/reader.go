@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// backupReader gives uniform read access to a backup regardless of its format.
+type backupReader struct {
+	path     string
+	format   string
+	zip      *zip.ReadCloser
+	snapshot *Manifest
+	objects  map[string]string
+}
+
+func openBackup(path string) (*backupReader, error) {
+	r := &backupReader{path: path}
+
+	switch filepath.Ext(path) {
+	case zipExtension:
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		r.format, r.zip = FormatZip, zr
+	case snapshotExtension:
+		m, err := readManifest(path)
+		if err != nil {
+			return nil, err
+		}
+		r.format, r.snapshot = FormatRepo, m
+		r.objects = make(map[string]string, len(m.Entries))
+		for _, entry := range m.Entries {
+			if !entry.IsDir() {
+				r.objects[entry.Path] = entry.SHA256
+			}
+		}
+	default:
+		r.format = FormatDir
+	}
+
+	return r, nil
+}
+
+func (r *backupReader) Close() error {
+	if r.zip != nil {
+		return r.zip.Close()
+	}
+
+	return nil
+}
+
+// Manifest returns the manifest recorded when the backup was taken.
+func (r *backupReader) Manifest() (*Manifest, error) {
+	switch r.format {
+	case FormatRepo:
+		return r.snapshot, nil
+	case FormatZip:
+		in, err := r.zip.Open(manifestFileName)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = in.Close() }()
+
+		var m Manifest
+		if err := json.NewDecoder(in).Decode(&m); err != nil {
+			return nil, err
+		}
+		return &m, nil
+	default:
+		return readManifest(filepath.Join(r.path, manifestFileName))
+	}
+}
+
+// Entries lists the directories and files that are actually present in the backup,
+// without hashes and without the manifest itself.
+func (r *backupReader) Entries() ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+
+	switch r.format {
+	case FormatRepo:
+		// a snapshot only holds what its manifest references, so report the objects
+		// that can still be found in the store
+		for _, entry := range r.snapshot.Entries {
+			if !entry.IsDir() {
+				info, err := os.Stat(r.objectPath(entry.SHA256))
+				if err != nil {
+					continue
+				}
+				entry.Size = info.Size()
+			}
+			entry.SHA256 = ""
+			entries = append(entries, entry)
+		}
+	case FormatZip:
+		for _, f := range r.zip.File {
+			name := strings.TrimSuffix(f.Name, "/")
+			if name == manifestFileName {
+				continue
+			}
+			entry := ManifestEntry{Path: name, Mode: f.Mode(), ModTime: f.Modified}
+			if !f.FileInfo().IsDir() {
+				entry.Size = int64(f.UncompressedSize64)
+			}
+			entries = append(entries, entry)
+		}
+	default:
+		walked, err := walkManifestEntries(r.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range walked {
+			if entry.Path != manifestFileName {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// Open opens the file at the slash separated path relative to the backup root.
+func (r *backupReader) Open(path string) (io.ReadCloser, error) {
+	switch r.format {
+	case FormatRepo:
+		hash, ok := r.objects[path]
+		if !ok {
+			return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+		}
+		return os.Open(r.objectPath(hash))
+	case FormatZip:
+		return r.zip.Open(path)
+	default:
+		return os.Open(filepath.Join(r.path, filepath.FromSlash(path)))
+	}
+}
+
+func (r *backupReader) objectPath(hash string) string {
+	return objectPath(filepath.Join(filepath.Dir(r.path), repoObjectsDir), hash)
+}
@@ -0,0 +1,280 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	FormatRepo        = "repo"
+	repoObjectsDir    = ".objects"
+	snapshotExtension = ".snapshot"
+	tmpExtension      = ".tmp"
+)
+
+// storeSnapshot stores every file below src once by its SHA-256 in the object store of
+// dstPath and writes a snapshot manifest that points at those objects.
+func storeSnapshot(src, snapshotPath string, dirCounter, fileCounter *int, numOfWorkers int, newManifest func([]ManifestEntry) *Manifest) error {
+	objects := filepath.Join(filepath.Dir(snapshotPath), repoObjectsDir)
+	if err := createIfNotExists(objects, Mode0755); err != nil {
+		return err
+	}
+
+	entries, err := walkManifestEntries(src)
+	if err != nil {
+		return err
+	}
+
+	err = parallelFor(len(entries), numOfWorkers, func(i int) error {
+		if entries[i].IsDir() {
+			return nil
+		}
+
+		path := filepath.Join(src, filepath.FromSlash(entries[i].Path))
+		hash, err := hashFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ErrHashingFile, err)
+		}
+		entries[i].SHA256 = hash
+
+		if err := storeObject(path, objectPath(objects, hash)); err != nil {
+			return fmt.Errorf("%s: %v", ErrStoringObject, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			*dirCounter += 1
+		} else {
+			*fileCounter += 1
+		}
+	}
+
+	return writeManifest(snapshotPath, newManifest(entries))
+}
+
+// restoreSnapshot materializes the files referenced by the snapshot manifest below dst,
+// every file is checked against the hash it is stored by.
+func restoreSnapshot(snapshotPath, dst string, dirCounter, fileCounter *int, numOfWorkers int) error {
+	objects := filepath.Join(filepath.Dir(snapshotPath), repoObjectsDir)
+
+	m, err := readManifest(snapshotPath)
+	if err != nil {
+		return err
+	}
+
+	// a manifest may only place files below dst and point at objects inside the store
+	for _, entry := range m.Entries {
+		if !filepath.IsLocal(filepath.FromSlash(entry.Path)) {
+			return fmt.Errorf("%s: %s", ErrInvalidArchiveEntry, entry.Path)
+		}
+		if entry.IsDir() {
+			continue
+		}
+		if hash, err := hex.DecodeString(entry.SHA256); err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("%s: %s", ErrInvalidArchiveEntry, entry.Path)
+		}
+	}
+
+	// directories are created up front so the workers only need to copy files
+	for _, entry := range m.Entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if err := createIfNotExists(filepath.Join(dst, filepath.FromSlash(entry.Path)), Mode0755); err != nil {
+			return err
+		}
+		*dirCounter += 1
+	}
+
+	err = parallelFor(len(m.Entries), numOfWorkers, func(i int) error {
+		entry := m.Entries[i]
+		if entry.IsDir() {
+			return nil
+		}
+
+		path := filepath.Join(dst, filepath.FromSlash(entry.Path))
+		if err := copyFile(objectPath(objects, entry.SHA256), path); err != nil {
+			return fmt.Errorf("%s: %v", ErrCopyFile, err)
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ErrHashingFile, err)
+		}
+		if hash != entry.SHA256 {
+			return fmt.Errorf("%s: %s", ErrObjectMismatch, entry.Path)
+		}
+		if err := os.Chmod(path, entry.Mode); err != nil {
+			return err
+		}
+
+		return os.Chtimes(path, entry.ModTime, entry.ModTime)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range m.Entries {
+		if !entry.IsDir() {
+			*fileCounter += 1
+		}
+	}
+
+	return nil
+}
+
+// gcObjects removes every object of the store in backupPath that is no longer
+// referenced by any snapshot and returns the number of objects removed.  Objects
+// stored or reused within staleStagingAge are kept, as a backup running in another
+// process may not have written the snapshot referencing them yet.
+func gcObjects(backupPath string) (int, error) {
+	objects := filepath.Join(backupPath, repoObjectsDir)
+	if !exists(objects) {
+		return 0, nil
+	}
+
+	refCounts, err := countObjectReferences(backupPath)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	err = filepath.WalkDir(objects, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if refCounts[d.Name()] > 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < staleStagingAge {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+
+		return nil
+	})
+
+	return removed, err
+}
+
+func countObjectReferences(backupPath string) (map[string]int, error) {
+	refCounts := make(map[string]int)
+
+	entries, err := os.ReadDir(backupPath)
+	if err != nil {
+		return refCounts, err
+	}
+
+	for _, entry := range entries {
+		// snapshots still being staged reference objects too
+		name := strings.TrimSuffix(entry.Name(), stagingExtension)
+		if entry.IsDir() || !strings.HasSuffix(name, snapshotExtension) {
+			continue
+		}
+
+		m, err := readManifest(filepath.Join(backupPath, entry.Name()))
+		if err != nil {
+			return refCounts, err
+		}
+		for _, e := range m.Entries {
+			if e.SHA256 != "" {
+				refCounts[e.SHA256]++
+			}
+		}
+	}
+
+	return refCounts, nil
+}
+
+func storeObject(src, dst string) error {
+	// identical content is already stored, touching it keeps gcObjects from removing it
+	// before the snapshot referencing it is written
+	if exists(dst) {
+		now := time.Now()
+		return os.Chtimes(dst, now, now)
+	}
+
+	if err := createIfNotExists(filepath.Dir(dst), Mode0755); err != nil {
+		return err
+	}
+
+	// workers may store identical content concurrently, so every writer gets its own
+	// temporary file and the last rename wins
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+"-*"+tmpExtension)
+	if err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := copyFile(src, tmp.Name()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
+
+func objectPath(objects, hash string) string {
+	if len(hash) < 2 {
+		return filepath.Join(objects, hash)
+	}
+
+	return filepath.Join(objects, hash[:2], hash)
+}
+
+// walkManifestEntries returns an entry for every directory and file below root with
+// slash separated paths relative to root.
+func walkManifestEntries(root string) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		entry := ManifestEntry{
+			Path:    filepath.ToSlash(rel),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		}
+		if !d.IsDir() {
+			entry.Size = info.Size()
+		}
+		entries = append(entries, entry)
+
+		return nil
+	})
+
+	return entries, err
+}
@@ -4,7 +4,8 @@ import (
 	"fmt"
 	"github.com/spf13/viper"
 	"os"
-	"slices"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -13,8 +14,15 @@ const (
 )
 
 type Restore struct {
-	RestoreFile string
-	Backup      *Backup
+	RestoreFile      string
+	Target           string
+	DryRun           bool
+	Force            bool
+	BackupFirst      bool
+	Preview          *RestorePreview
+	Backup           *Backup
+	restoreTimestamp time.Time
+	journal          *RestoreJournal
 }
 
 func NewRestore(restoreFile string, backup *Backup) *Restore {
@@ -25,7 +33,8 @@ func NewRestore(restoreFile string, backup *Backup) *Restore {
 }
 
 func (r *Restore) RestoreNoita() {
-	if !isNoitaRunning() {
+	// extracting to a target never touches save00, so Noita may keep running
+	if process, running := r.Backup.detector.NoitaProcess(); !running || r.Target != "" {
 		if r.Backup.phase == stopped {
 			if r.Backup.async {
 				go func() { _ = r.restoreNoita() }()
@@ -36,7 +45,7 @@ func (r *Restore) RestoreNoita() {
 			r.Backup.LogRing.LogAndAppend(ErrOperationAlreadyInProgress)
 		}
 	} else {
-		r.Backup.LogRing.LogAndAppend(fmt.Sprintf("%s %s", ErrNoitaRunning, ErrDuringRestore))
+		r.Backup.LogRing.LogAndAppend(noitaRunningError(process, ErrDuringRestore))
 	}
 }
 
@@ -58,20 +67,98 @@ func (r *Restore) restoreNoita() error {
 	}
 
 	// check the Backup directory for the specified Backup to restore
-	if r.RestoreFile != "latest" {
-		Backups := convertTimeSliceToStrings(r.Backup.sortedBackupDirs)
-		if !slices.Contains(Backups, r.RestoreFile) {
-			return r.restorePost(fmt.Sprintf(ErrBackupNotFound, r.RestoreFile), false)
+	r.restoreTimestamp, err = findBackup(r.Backup.sortedBackupDirs, r.RestoreFile)
+	if err != nil {
+		return r.restorePost(err.Error(), false)
+	}
+
+	// refuse to restore a backup that no longer matches its manifest
+	result := verifyBackup(getBackupPath(r.Backup.dstPath, r.restoreTimestamp), r.restoreTimestamp, viper.GetInt(ViperNumWorkers))
+	if !result.OK() {
+		return r.restorePost(fmt.Sprintf("%s: %s", ErrBackupCorrupt, strings.Join(result.Problems(), ", ")), false)
+	}
+	if result.Unverifiable {
+		r.Backup.LogRing.LogAndAppend(InfoNoManifest)
+	}
+
+	// describe the save about to be restored
+	if metadata := readBackupMetadata(getBackupPath(r.Backup.dstPath, r.restoreTimestamp)); metadata != nil {
+		r.Backup.LogRing.LogAndAppend(fmt.Sprintf(InfoRestoringSave, r.restoreTimestamp.Format(TimeFormat), metadata))
+	}
+
+	// extract to the target and leave save00 alone
+	if r.Target != "" {
+		if err := checkExtractTarget(r.Target, r.Backup.srcPath, r.Backup.dstPath); err != nil {
+			return r.restorePost(fmt.Sprintf("%s: %v", ErrExtractingBackup, err), false)
+		}
+		if r.DryRun {
+			return r.preview(r.Target)
+		}
+		if err := r.extractBackup(r.Target); err != nil {
+			return r.restorePost(fmt.Sprintf("%s: %v", ErrExtractingBackup, err), false)
+		}
+		r.Backup.LogRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoSuccessfulExtract, r.Target))
+		r.Backup.reportStop()
+		r.Backup.resetPhase()
+		return nil
+	}
+
+	// never rotate a half restored save00 into the generations
+	if journal, err := ReadRestoreJournal(r.Backup.dstPath); err != nil {
+		return r.restorePost(err.Error(), false)
+	} else if journal.Incomplete() {
+		return r.restorePost(fmt.Sprintf("%s: %s", ErrRestorePending, journal), false)
+	}
+
+	// progress no backup holds yet would only survive in save00.bak.1, a forced restore
+	// discards it knowingly and a failed comparison only warns
+	var unsaved *UnsavedProgress
+	if !r.Force {
+		unsaved, err = unsavedProgress(r.Backup.srcPath, r.Backup.dstPath, r.Backup.sortedBackupDirs[len(r.Backup.sortedBackupDirs)-1])
+		if err != nil {
+			r.Backup.LogRing.LogAndAppend(fmt.Sprintf("%s: %v", ErrComparingSave00, err))
 		}
 	}
 
+	// stop after all the checks and describe what a restore would change
+	if r.DryRun {
+		if err := r.preview(r.Backup.srcPath); err != nil {
+			return err
+		}
+		r.Preview.Unsaved = unsaved
+		return nil
+	}
+
+	if unsaved != nil {
+		r.Backup.LogRing.LogAndAppend(unsaved.String())
+		if !r.BackupFirst {
+			return r.restorePost(ErrUnsavedProgress, false)
+		}
+		if err := r.backupFirst(); err != nil {
+			return r.restorePost(fmt.Sprintf("%s: %v", ErrBackupFirst, err), false)
+		}
+	}
+
+	// journal every phase from here on so a crash can be recovered from on the next start
+	r.journal = &RestoreJournal{Backup: r.restoreTimestamp.Format(TimeFormat), Source: r.Backup.srcPath}
+	if err := writeJournal(r.Backup.dstPath, r.journal, JournalRotating); err != nil {
+		return r.restorePost(fmt.Sprintf("%s: %v", ErrWritingJournal, err), false)
+	}
+
 	// process save00
-	// 1. delete save00.bak
-	// 2. rename save00 -> save00.bak
+	// 1. shift save00.bak.1 .. save00.bak.N up by one, dropping the oldest
+	// 2. rename save00 -> save00.bak.1
 	if err := r.processSave00(); err != nil {
+		if err := r.journal.RollBack(r.Backup.LogRing, r.Backup.dstPath); err != nil {
+			r.Backup.LogRing.LogAndAppend(err.Error())
+		}
 		return r.restorePost(fmt.Sprintf("%s: %v", ErrProcessingSave00, err), false)
 	}
 
+	if err := writeJournal(r.Backup.dstPath, r.journal, JournalRotated); err != nil {
+		return r.restorePost(fmt.Sprintf("%s: %v", ErrWritingJournal, err), true)
+	}
+
 	// restore specified (default latest) Backup to destination
 	if err := r.restoreSave00(); err != nil {
 		return r.restorePost(fmt.Sprintf("%s: %v", ErrRestoringToSave00, err), true)
@@ -87,26 +174,25 @@ func (r *Restore) restoreSave00() error {
 	// create destination directory
 	r.Backup.LogRing.LogAndAppend(InfoCreatingSave00)
 
-	// create directory
-	err := os.MkdirAll(r.Backup.srcPath, os.ModePerm)
-	if err != nil {
+	if err := writeJournal(r.Backup.dstPath, r.journal, JournalCopying); err != nil {
 		return err
 	}
 
-	// recursively copy source to destination
-	latest := fmt.Sprintf("%s\\%s", r.Backup.dstPath, r.Backup.sortedBackupDirs[len(r.Backup.sortedBackupDirs)-1].Format(TimeFormat))
-	r.Backup.LogRing.LogAndAppend(fmt.Sprintf(InfoCopyBackup, latest))
-	if err := concurrentCopy(latest, r.Backup.srcPath, &r.Backup.dirCounter, &r.Backup.fileCounter, viper.GetInt("num-workers")); err != nil {
+	if err := r.extractBackup(r.Backup.srcPath); err != nil {
 		r.Backup.LogRing.LogAndAppend(fmt.Sprintf("%s: %v", ErrCopyingToSave00, err))
 		r.Backup.phase = stopped
 		return err
 	}
 
-	r.Backup.LogRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoSuccessfulRestore, latest))
+	if err := writeJournal(r.Backup.dstPath, r.journal, JournalComplete); err != nil {
+		return err
+	}
+
+	r.Backup.LogRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoSuccessfulRestore, getBackupPath(r.Backup.dstPath, r.restoreTimestamp)))
 
 	// launch noita after successful restore
 	if r.Backup.autoLaunchChecked {
-		err = LaunchNoita(r.Backup.async)
+		err := LaunchNoita(r.Backup.async, r.Backup.detector)
 		if err != nil {
 			r.Backup.LogRing.LogAndAppend(fmt.Sprintf("%s: %v", ErrFailedToLaunch, err))
 		}
@@ -115,31 +201,251 @@ func (r *Restore) restoreSave00() error {
 	return nil
 }
 
-func (r *Restore) deleteSave00Bak() error {
-	r.Backup.LogRing.LogAndAppend(InfoDeletingSave00Bak)
-	err := os.RemoveAll(fmt.Sprintf("%s%s", r.Backup.srcPath, backupSuffix))
+// RestorePreview describes what restoring a backup would change in its destination.
+type RestorePreview struct {
+	Backup      string           `json:"backup"`
+	Destination string           `json:"destination"`
+	Metadata    *SaveMetadata    `json:"metadata,omitempty"`
+	Files       []FileChange     `json:"files"`
+	Bytes       int64            `json:"bytes"`
+	Unsaved     *UnsavedProgress `json:"unsaved,omitempty"`
+}
+
+// CountFiles counts the file changes of the given kind.
+func (p *RestorePreview) CountFiles(change string) int {
+	return countFiles(p.Files, change)
+}
+
+func (p *RestorePreview) String() string {
+	return fmt.Sprintf(InfoRestorePreview,
+		p.Backup,
+		p.CountFiles(FileChanged),
+		p.CountFiles(FileAdded),
+		p.CountFiles(FileRemoved),
+		FormatSize(p.Bytes),
+		p.Destination,
+	)
+}
+
+// UnsavedProgress describes progress in save00 that is newer than the newest backup.
+type UnsavedProgress struct {
+	Backup         string    `json:"backup"`
+	LiveModified   time.Time `json:"live_modified"`
+	BackupModified time.Time `json:"backup_modified"`
+	LiveSessions   int       `json:"live_sessions"`
+	BackupSessions int       `json:"backup_sessions"`
+}
+
+func (u *UnsavedProgress) String() string {
+	return fmt.Sprintf(InfoUnsavedProgress,
+		u.LiveModified.Format(LogRingTimeFormat),
+		u.LiveSessions,
+		u.Backup,
+		u.BackupModified.Format(LogRingTimeFormat),
+		u.BackupSessions,
+	)
+}
+
+// unsavedProgress compares the newest modification time and the session count of save00
+// with the newest backup and returns nil unless save00 is ahead in either.
+func unsavedProgress(srcPath, backupPath string, newest time.Time) (*UnsavedProgress, error) {
+	if !exists(srcPath) {
+		return nil, nil
+	}
+
+	unsaved := &UnsavedProgress{Backup: newest.Format(TimeFormat)}
+	var err error
+	if unsaved.LiveModified, unsaved.LiveSessions, err = saveActivity(srcPath); err != nil {
+		return nil, err
+	}
+	if unsaved.BackupModified, unsaved.BackupSessions, err = saveActivity(getBackupPath(backupPath, newest)); err != nil {
+		return nil, err
+	}
+
+	// archives keep modification times to the second only
+	if unsaved.LiveModified.Truncate(time.Second).After(unsaved.BackupModified.Truncate(time.Second)) ||
+		unsaved.LiveSessions > unsaved.BackupSessions {
+		return unsaved, nil
+	}
+
+	return nil, nil
+}
+
+// saveActivity returns the newest file modification time and the number of sessions
+// recorded in a save or backup.
+func saveActivity(savePath string) (time.Time, int, error) {
+	var modified time.Time
+	sessions := 0
+
+	r, err := openBackup(savePath)
+	if err != nil {
+		return modified, sessions, err
+	}
+	defer func() { _ = r.Close() }()
+
+	entries, err := r.Entries()
+	if err != nil {
+		return modified, sessions, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if entry.ModTime.After(modified) {
+			modified = entry.ModTime
+		}
+		if isSessionStats(entry.Path) {
+			sessions++
+		}
+	}
+
+	return modified, sessions, nil
+}
+
+// backupFirst backs up save00 before it is replaced, without launching Noita afterwards.
+func (r *Restore) backupFirst() error {
+	autoLaunch := r.Backup.autoLaunchChecked
+	r.Backup.autoLaunchChecked = false
+	err := r.Backup.backupNoita()
+	r.Backup.autoLaunchChecked = autoLaunch
+	r.Backup.phase = started
 	if err != nil {
 		return err
 	}
 
+	// the backup may have made room for itself by removing the one to restore
+	if backupPath := getBackupPath(r.Backup.dstPath, r.restoreTimestamp); !exists(backupPath) {
+		return fmt.Errorf("%s: %s", ErrBackupRemoved, backupPath)
+	}
+
 	return nil
 }
 
-func (r *Restore) processSave00() error {
-	err := r.deleteSave00Bak()
+// preview compares the selected backup with dst, leaving both untouched.
+func (r *Restore) preview(dst string) error {
+	backupPath := getBackupPath(r.Backup.dstPath, r.restoreTimestamp)
+	backup, err := openBackup(backupPath)
+	if err != nil {
+		return r.restorePost(fmt.Sprintf("%s: %v", ErrOpeningBackup, err), false)
+	}
+	defer func() { _ = backup.Close() }()
+
+	// a missing destination is compared as an empty one
+	var current *backupReader
+	if exists(dst) {
+		if current, err = openBackup(dst); err != nil {
+			return r.restorePost(fmt.Sprintf("%s: %v", ErrOpeningBackup, err), false)
+		}
+	}
+
+	preview := &RestorePreview{
+		Backup:      r.restoreTimestamp.Format(TimeFormat),
+		Destination: dst,
+		Metadata:    readBackupMetadata(backupPath),
+	}
+	if preview.Files, err = diffFiles(current, backup); err != nil {
+		return r.restorePost(fmt.Sprintf("%s: %v", ErrDiffFailed, err), false)
+	}
+	files, err := fileEntries(backup)
+	if err != nil {
+		return r.restorePost(fmt.Sprintf("%s: %v", ErrDiffFailed, err), false)
+	}
+	for _, entry := range files {
+		preview.Bytes += entry.Size
+	}
+
+	r.Preview = preview
+	r.Backup.LogRing.LogAndAppend(preview.String())
+	r.Backup.resetPhase()
+
+	return nil
+}
+
+// extractBackup recursively copies the selected backup into dst, leaving out the manifest.
+func (r *Restore) extractBackup(dst string) error {
+	// create directory
+	err := os.MkdirAll(dst, os.ModePerm)
 	if err != nil {
 		return err
 	}
 
-	r.Backup.LogRing.LogAndAppend(InfoRename)
-	err = os.Rename(r.Backup.srcPath, fmt.Sprintf("%s%s", r.Backup.srcPath, backupSuffix))
+	// recursively copy source to destination, the manifest describes the backup and is not
+	// part of the save
+	backupPath := getBackupPath(r.Backup.dstPath, r.restoreTimestamp)
+	r.Backup.LogRing.LogAndAppend(fmt.Sprintf(InfoCopyBackup, backupPath, dst))
+	switch filepath.Ext(backupPath) {
+	case zipExtension:
+		err = extractZip(backupPath, dst, manifestFileName, &r.Backup.dirCounter, &r.Backup.fileCounter)
+	case snapshotExtension:
+		err = restoreSnapshot(backupPath, dst, &r.Backup.dirCounter, &r.Backup.fileCounter, viper.GetInt("num-workers"))
+	default:
+		err = concurrentCopy(backupPath, dst, "", manifestFileName, &r.Backup.dirCounter, &r.Backup.fileCounter, viper.GetInt("num-workers"))
+	}
+
+	return err
+}
+
+// checkExtractTarget refuses targets at or below the live save, its generations or the
+// backup directory, and targets that already hold files.
+func checkExtractTarget(target, srcPath, dstPath string) error {
+	targetAbs, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	srcAbs, err := filepath.Abs(srcPath)
 	if err != nil {
 		return err
 	}
+	dstAbs, err := filepath.Abs(dstPath)
+	if err != nil {
+		return err
+	}
+
+	for path := targetAbs; ; path = filepath.Dir(path) {
+		if samePath(filepath.Dir(path), filepath.Dir(srcAbs)) && isSaveName(filepath.Base(path), filepath.Base(srcAbs)) {
+			return fmt.Errorf(ErrTargetIsSave00, target)
+		}
+		if samePath(path, dstAbs) {
+			return fmt.Errorf(ErrTargetInBackups, target)
+		}
+		if filepath.Dir(path) == path {
+			break
+		}
+	}
+
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf(ErrTargetNotEmpty, target)
+	}
 
 	return nil
 }
 
+// isSaveName reports whether name is the save itself, one of its generations, the
+// save00.bak left by older versions or its undo copy.
+func isSaveName(name, save string) bool {
+	if samePath(name, save) || samePath(name, save+backupSuffix) || samePath(name, save+undoSuffix) {
+		return true
+	}
+
+	prefix := save + backupSuffix + "."
+	if len(name) <= len(prefix) || !samePath(name[:len(prefix)], prefix) {
+		return false
+	}
+
+	return strings.Trim(name[len(prefix):], "0123456789") == ""
+}
+
+func (r *Restore) processSave00() error {
+	return rotateGenerations(r.Backup.LogRing, r.Backup.srcPath, viper.GetInt(ViperGenerations))
+}
+
 func (r *Restore) restorePost(errorMessage string, cleanup bool) error {
 	r.Backup.LogRing.LogAndAppend(errorMessage)
 
@@ -152,24 +458,19 @@ func (r *Restore) restorePost(errorMessage string, cleanup bool) error {
 			}
 		}
 
-		// restore save00.bak due to failure
-		if exists(fmt.Sprintf(r.Backup.srcPath, backupSuffix)) {
-			r.Backup.LogRing.LogAndAppend(InfoRenameRestore)
-			if err := os.Rename(fmt.Sprintf("%s%s", r.Backup.srcPath, backupSuffix), r.Backup.srcPath); err != nil {
-				r.Backup.phase = stopped
-				return err
-			}
+		// restore save00.bak.1 due to failure
+		if err := unrotateGenerations(r.Backup.LogRing, r.Backup.srcPath); err != nil {
+			r.Backup.phase = stopped
+			return err
+		}
+
+		// rolled back in full, nothing is left to recover on the next start
+		if err := removeJournal(r.Backup.dstPath); err != nil {
+			r.Backup.phase = stopped
+			return err
 		}
 	}
 
 	r.Backup.phase = stopped
 	return fmt.Errorf(errorMessage)
 }
-
-func convertTimeSliceToStrings(timeSlice []time.Time) []string {
-	var stringSlice []string
-	for _, t := range timeSlice {
-		stringSlice = append(stringSlice, t.Format(TimeFormat))
-	}
-	return stringSlice
-}
@@ -1,15 +1,17 @@
 package internal
 
 import (
+	"github.com/spf13/viper"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 )
 
-const (
-	TestSourcePath = "test_source_path\\save00"
+var (
+	TestSourcePath = filepath.Join("test_source_path", "save00")
 )
 
 type Node struct {
@@ -21,6 +23,28 @@ type DirectoryTree struct {
 	Root *Node
 }
 
+// writeFiles creates each file with its content, along with missing parent directories
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFile returns the content of a file, or an empty string when it cannot be read
+func readFile(path string) string {
+	got, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(got)
+}
+
 func TestRestore_RestoreNoita(t *testing.T) {
 	// create a mock source save00 directory structure
 	if err := newNoitaSourceDirs(); err != nil {
@@ -36,14 +60,14 @@ func TestRestore_RestoreNoita(t *testing.T) {
 	}
 
 	// create a new restore
-	backup := NewBackup(false, false, 16, TestSourcePath, TestBackupPath)
+	backup := NewBackup(false, false, 16, TestSourcePath, TestBackupPath, fakeProcessDetector{})
 	restore := NewRestore("latest", backup)
 	if err := restore.restoreNoita(); err != nil {
 		t.Fatal(err)
 	}
 
 	// cleanup
-	if err := os.RemoveAll(strings.Split(TestSourcePath, "\\")[0]); err != nil {
+	if err := os.RemoveAll(filepath.Dir(TestSourcePath)); err != nil {
 		t.Fatal(err)
 	}
 
@@ -131,3 +155,560 @@ func (n *Node) createDirectories(path string) error {
 
 	return nil
 }
+
+func TestFindBackup(t *testing.T) {
+	backupDirs := []time.Time{
+		time.Date(2024, 6, 11, 20, 0, 0, 0, time.UTC),
+		time.Date(2024, 6, 12, 17, 49, 18, 0, time.UTC),
+		time.Date(2024, 6, 12, 18, 0, 0, 0, time.UTC),
+		time.Date(2024, 6, 13, 9, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name      string
+		id        string
+		expected  time.Time
+		expectErr bool
+	}{
+		{name: "latest", id: "latest", expected: backupDirs[3]},
+		{name: "empty is latest", id: "", expected: backupDirs[3]},
+		{name: "latest offset", id: "latest~3", expected: backupDirs[0]},
+		{name: "latest offset out of range", id: "latest~4", expectErr: true},
+		{name: "invalid offset", id: "latest~x", expectErr: true},
+		{name: "exact timestamp", id: "2024-06-12-17-49-18", expected: backupDirs[1]},
+		{name: "date prefix picks newest", id: "2024-06-12", expected: backupDirs[2]},
+		{name: "not found", id: "2023-01-01", expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findBackup(backupDirs, tt.id)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("findBackup() error = %v, expected %v", err, tt.expectErr)
+			}
+			if !tt.expectErr && !got.Equal(tt.expected) {
+				t.Errorf("findBackup() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRestore_RestoreNoitaByID(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "save00")
+	dst := t.TempDir()
+	if err := os.MkdirAll(src, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	older := time.Date(2024, 6, 12, 17, 49, 18, 0, time.UTC)
+	for i, ts := range []time.Time{older, older.Add(time.Hour)} {
+		dir := filepath.Join(dst, ts.Format(TimeFormat))
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "player.xml"), []byte{byte('0' + i)}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restore := NewRestore("latest~1", NewBackup(false, false, 16, src, dst, fakeProcessDetector{}))
+	if err := restore.restoreNoita(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(src, "player.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "0" {
+		t.Errorf("restored player.xml = %q, expected the older backup", got)
+	}
+}
+
+func TestRestore_Generations(t *testing.T) {
+	viper.Set(ViperGenerations, 2)
+	defer viper.Set(ViperGenerations, nil)
+
+	src := filepath.Join(t.TempDir(), "save00")
+	dst := t.TempDir()
+	readSave := func(dir string) string {
+		return readFile(filepath.Join(dir, "player.xml"))
+	}
+	writeFiles(t, map[string]string{
+		filepath.Join(src, "player.xml"):                                "live",
+		filepath.Join(src+backupSuffix, "player.xml"):                   "legacy",
+		filepath.Join(dst, time.Now().Format(TimeFormat), "player.xml"): "backup",
+	})
+
+	// the legacy save00.bak becomes a generation and is kept by the first restore
+	restore := NewRestore(StrLatest, NewBackup(false, false, 16, src, dst, fakeProcessDetector{}))
+	if err := restore.restoreNoita(); err != nil {
+		t.Fatal(err)
+	}
+	if got := []string{readSave(src), readSave(generationPath(src, 1)), readSave(generationPath(src, 2))}; !reflect.DeepEqual(got, []string{"backup", "live", "legacy"}) {
+		t.Errorf("restoreNoita() left save00 and its generations as %q", got)
+	}
+	if exists(src + backupSuffix) {
+		t.Error("restoreNoita() kept the legacy save00.bak")
+	}
+
+	// a second restore keeps the save it replaced and drops the oldest generation
+	writeFiles(t, map[string]string{filepath.Join(src, "player.xml"): "played"})
+	restore.Force = true
+	if err := restore.restoreNoita(); err != nil {
+		t.Fatal(err)
+	}
+	if got := []string{readSave(src), readSave(generationPath(src, 1)), readSave(generationPath(src, 2))}; !reflect.DeepEqual(got, []string{"backup", "played", "live"}) {
+		t.Errorf("restoreNoita() left save00 and its generations as %q", got)
+	}
+	if exists(generationPath(src, 3)) {
+		t.Error("restoreNoita() kept more generations than configured")
+	}
+
+	// undo swaps save00 with the first generation, twice swaps back
+	if err := UndoRestore(NewLogRing(16), src, dst, fakeProcessDetector{running: true}); err == nil {
+		t.Error("UndoRestore() succeeded while Noita was running")
+	}
+	journal := &RestoreJournal{Backup: time.Now().Format(TimeFormat), Source: src}
+	if err := writeJournal(dst, journal, JournalCopying); err != nil {
+		t.Fatal(err)
+	}
+	if err := UndoRestore(NewLogRing(16), src, dst, fakeProcessDetector{}); err == nil || !strings.Contains(err.Error(), ErrRestorePending) {
+		t.Errorf("UndoRestore() = %v, expected %q", err, ErrRestorePending)
+	}
+	if err := removeJournal(dst); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range [][]string{{"played", "backup"}, {"backup", "played"}} {
+		if err := UndoRestore(NewLogRing(16), src, dst, fakeProcessDetector{}); err != nil {
+			t.Fatal(err)
+		}
+		if got := []string{readSave(src), readSave(generationPath(src, 1))}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("UndoRestore() left save00 and save00.bak.1 as %q, expected %q", got, expected)
+		}
+	}
+}
+
+func TestRestore_RollbackOnFailedCopy(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "save00")
+	dst := t.TempDir()
+	writeFiles(t, map[string]string{filepath.Join(src, "player.xml"): "live"})
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(src, "player.xml"), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	// a directory backup without manifest cannot be verified, a dangling link in it
+	// fails the copy after save00 was rotated
+	backup := filepath.Join(dst, time.Now().Format(TimeFormat))
+	writeFiles(t, map[string]string{filepath.Join(backup, "world_state.xml"): "backup"})
+	if err := os.Symlink(filepath.Join(dst, "missing.xml"), filepath.Join(backup, "player.xml")); err != nil {
+		t.Skipf("cannot create symlink: %v", err)
+	}
+
+	restore := NewRestore(StrLatest, NewBackup(false, false, 16, src, dst, fakeProcessDetector{}))
+	if err := restore.restoreNoita(); err == nil || !strings.Contains(err.Error(), ErrRestoringToSave00) {
+		t.Fatalf("restoreNoita() = %v, expected %q", err, ErrRestoringToSave00)
+	}
+
+	if got := readFile(filepath.Join(src, "player.xml")); got != "live" {
+		t.Errorf("restoreNoita() left save00 player.xml as %q, expected it rolled back", got)
+	}
+	if exists(generationPath(src, 1)) {
+		t.Error("restoreNoita() left save00.bak.1 behind after rolling back")
+	}
+	if journal, err := ReadRestoreJournal(dst); journal != nil || err != nil {
+		t.Errorf("restoreNoita() left journal %v, %v after rolling back", journal, err)
+	}
+}
+
+func TestRestoreJournal(t *testing.T) {
+	for _, forward := range []bool{true, false} {
+		src := filepath.Join(t.TempDir(), "save00")
+		dst := t.TempDir()
+		id := time.Now().Format(TimeFormat)
+		writeFiles(t, map[string]string{
+			filepath.Join(src, "player.xml"):           "live",
+			filepath.Join(dst, id, "player.xml"):       "backup",
+			filepath.Join(dst, id, "world_state.xml"):  "world",
+			filepath.Join(generationPath(src, 1), "x"): "older",
+		})
+
+		// crash halfway through the copy of a restore
+		if err := rotateGenerations(NewLogRing(1), src, 3); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeJournal(dst, &RestoreJournal{Backup: id, Source: src}, JournalCopying); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(src, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(src, "player.xml"), []byte("back"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		journal, err := ReadRestoreJournal(dst)
+		if err != nil || !journal.Incomplete() {
+			t.Fatalf("ReadRestoreJournal() = %v, %v, expected an incomplete restore", journal, err)
+		}
+		restore := NewRestore(StrLatest, NewBackup(false, false, 16, src, dst, fakeProcessDetector{}))
+		if err := restore.restoreNoita(); err == nil || !strings.Contains(err.Error(), ErrRestorePending) {
+			t.Errorf("restoreNoita() = %v, expected %q", err, ErrRestorePending)
+		}
+
+		if forward {
+			if err := journal.RollForward(NewLogRing(1), dst); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := os.ReadFile(filepath.Join(src, "world_state.xml")); err != nil || string(got) != "world" {
+				t.Errorf("RollForward() left world_state.xml as %q, %v", got, err)
+			}
+			if journal, err := ReadRestoreJournal(dst); err != nil || journal.Incomplete() {
+				t.Errorf("RollForward() left journal %v, %v, expected it complete", journal, err)
+			}
+			continue
+		}
+
+		if err := journal.RollBack(NewLogRing(1), dst); err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, path := range []string{filepath.Join(src, "player.xml"), filepath.Join(generationPath(src, 1), "x")} {
+			content, _ := os.ReadFile(path)
+			got = append(got, string(content))
+		}
+		if !reflect.DeepEqual(got, []string{"live", "older"}) {
+			t.Errorf("RollBack() left save00 and save00.bak.1 as %q", got)
+		}
+		if journal, err := ReadRestoreJournal(dst); journal != nil || err != nil {
+			t.Errorf("RollBack() left journal %v, %v", journal, err)
+		}
+	}
+}
+
+func TestRestoreJournal_Rotating(t *testing.T) {
+	viper.Set(ViperGenerations, 3)
+	defer viper.Set(ViperGenerations, nil)
+
+	for _, forward := range []bool{true, false} {
+		src := filepath.Join(t.TempDir(), "save00")
+		dst := t.TempDir()
+		id := time.Now().Format(TimeFormat)
+		// crash in the middle of shifting the generations, save00 was not moved yet
+		writeFiles(t, map[string]string{
+			filepath.Join(src, "player.xml"):                    "live",
+			filepath.Join(generationPath(src, 2), "player.xml"): "first",
+			filepath.Join(generationPath(src, 3), "player.xml"): "second",
+			filepath.Join(dst, id, "player.xml"):                "backup",
+		})
+		if err := writeJournal(dst, &RestoreJournal{Backup: id, Source: src}, JournalRotating); err != nil {
+			t.Fatal(err)
+		}
+		journal, err := ReadRestoreJournal(dst)
+		if err != nil || !journal.Incomplete() {
+			t.Fatalf("ReadRestoreJournal() = %v, %v, expected an incomplete restore", journal, err)
+		}
+
+		expected := []string{"live", "first", "second", ""}
+		if forward {
+			if err := journal.RollForward(NewLogRing(1), dst); err != nil {
+				t.Fatal(err)
+			}
+			expected = []string{"backup", "live", "first", "second"}
+		} else if err := journal.RollBack(NewLogRing(1), dst); err != nil {
+			t.Fatal(err)
+		}
+
+		got := []string{}
+		for _, dir := range []string{src, generationPath(src, 1), generationPath(src, 2), generationPath(src, 3)} {
+			got = append(got, readFile(filepath.Join(dir, "player.xml")))
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("recovering with forward %t left save00 and its generations as %q, expected %q", forward, got, expected)
+		}
+	}
+}
+
+func TestRestore_DryRun(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "save00")
+	dst := t.TempDir()
+	id := time.Now().Format(TimeFormat)
+	writeFiles(t, map[string]string{
+		filepath.Join(src, "player.xml"):     "live",
+		filepath.Join(src, "session.xml"):    "removed",
+		filepath.Join(dst, id, "player.xml"): "back",
+		filepath.Join(dst, id, "world.xml"):  "added",
+	})
+
+	restore := NewRestore(StrLatest, NewBackup(false, false, 16, src, dst, fakeProcessDetector{}))
+	restore.DryRun = true
+	if err := restore.restoreNoita(); err != nil {
+		t.Fatal(err)
+	}
+
+	preview := restore.Preview
+	if preview == nil || preview.Backup != id || preview.Bytes != 9 {
+		t.Fatalf("restoreNoita() preview = %+v, expected backup %s with 9 bytes to copy", preview, id)
+	}
+	changes := map[string]int{FileChanged: 1, FileAdded: 1, FileRemoved: 1}
+	for change, expected := range changes {
+		if got := preview.CountFiles(change); got != expected {
+			t.Errorf("restoreNoita() preview has %d %s files, expected %d", got, change, expected)
+		}
+	}
+	if got, err := os.ReadFile(filepath.Join(src, "player.xml")); err != nil || string(got) != "live" || exists(generationPath(src, 1)) {
+		t.Errorf("restoreNoita() changed save00 during a dry run: %q, %v", got, err)
+	}
+	if journal, err := ReadRestoreJournal(dst); journal != nil || err != nil {
+		t.Errorf("restoreNoita() wrote journal %v, %v during a dry run", journal, err)
+	}
+}
+
+func TestRestore_UnsavedProgress(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "save00")
+	dst := t.TempDir()
+	id := time.Now().Add(-time.Hour).Format(TimeFormat)
+	writeFiles(t, map[string]string{
+		filepath.Join(dst, id, "player.xml"):                                     "backup",
+		filepath.Join(src, "player.xml"):                                         "live",
+		filepath.Join(src, "stats", "sessions", "2024-06-13-09-30-00_stats.xml"): "<Stats></Stats>",
+	})
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dst, id, "player.xml"), past, past); err != nil {
+		t.Fatal(err)
+	}
+	readSave := func() string {
+		return readFile(filepath.Join(src, "player.xml"))
+	}
+
+	restore := NewRestore(StrLatest, NewBackup(false, false, 16, src, dst, fakeProcessDetector{}))
+	if err := restore.restoreNoita(); err == nil || !strings.Contains(err.Error(), ErrUnsavedProgress) {
+		t.Errorf("restoreNoita() = %v, expected %q", err, ErrUnsavedProgress)
+	}
+	if readSave() != "live" || exists(generationPath(src, 1)) {
+		t.Error("restoreNoita() replaced save00 with newer progress than the newest backup")
+	}
+
+	restore.DryRun = true
+	if err := restore.restoreNoita(); err != nil {
+		t.Fatal(err)
+	}
+	if unsaved := restore.Preview.Unsaved; unsaved == nil || unsaved.Backup != id || unsaved.LiveSessions != 1 || unsaved.BackupSessions != 0 {
+		t.Errorf("restoreNoita() preview unsaved = %+v, expected one session newer than %s", unsaved, id)
+	}
+
+	restore.DryRun, restore.BackupFirst = false, true
+	if err := restore.restoreNoita(); err != nil {
+		t.Fatal(err)
+	}
+	if readSave() != "backup" {
+		t.Errorf("restoreNoita() left save00 player.xml as %q, expected the backup", readSave())
+	}
+	backupDirs, err := getBackupDirs(dst, TimeFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backupDirs) != 2 {
+		t.Fatalf("restoreNoita() left %d backups, expected save00 backed up first", len(backupDirs))
+	}
+	if got, err := os.ReadFile(filepath.Join(getBackupPath(dst, backupDirs[1]), "player.xml")); err != nil || string(got) != "live" {
+		t.Errorf("restoreNoita() backed up player.xml as %q, %v, expected the live save", got, err)
+	}
+
+	// a newest backup that cannot be compared against only warns
+	writeFiles(t, map[string]string{
+		filepath.Join(src, "player.xml"):                                                "played",
+		filepath.Join(dst, time.Now().Add(time.Minute).Format(TimeFormat)+zipExtension): "not a zip",
+	})
+	restore = NewRestore(id, NewBackup(false, false, 16, src, dst, fakeProcessDetector{}))
+	if err := restore.restoreNoita(); err != nil {
+		t.Fatal(err)
+	}
+	if readSave() != "backup" {
+		t.Errorf("restoreNoita() left save00 player.xml as %q, expected the backup", readSave())
+	}
+}
+
+func TestRestore_RestoreNoitaRunning(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "save00")
+	dst := t.TempDir()
+	if err := os.MkdirAll(src, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dst, time.Now().Format(TimeFormat)), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	restore := NewRestore(StrLatest, NewBackup(false, false, 16, src, dst, fakeProcessDetector{running: true}))
+	restore.RestoreNoita()
+
+	if exists(generationPath(src, 1)) {
+		t.Error("RestoreNoita() moved save00 aside while Noita was running")
+	}
+	if logs := restore.Backup.LogRing.Print(); len(logs) == 0 || !strings.Contains(logs[len(logs)-1], ErrDuringRestore) {
+		t.Errorf("RestoreNoita() logged %q, expected %q", logs, ErrDuringRestore)
+	}
+}
+
+func TestRestore_RestoreNoitaTarget(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "save00")
+	dst := t.TempDir()
+	target := filepath.Join(t.TempDir(), "sandbox")
+	if err := os.MkdirAll(src, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "player.xml"), []byte("live"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	id := time.Now().Format(TimeFormat)
+	if err := os.MkdirAll(filepath.Join(dst, id), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, id, "player.xml"), []byte("backup"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Noita running does not matter as save00 is left alone
+	restore := NewRestore(StrLatest, NewBackup(false, false, 16, src, dst, fakeProcessDetector{running: true}))
+	restore.Target = target
+	restore.RestoreNoita()
+
+	if got, err := os.ReadFile(filepath.Join(target, "player.xml")); err != nil || string(got) != "backup" {
+		t.Errorf("RestoreNoita() extracted player.xml = %q, %v, expected the backup", got, err)
+	}
+	if got, err := os.ReadFile(filepath.Join(src, "player.xml")); err != nil || string(got) != "live" {
+		t.Errorf("RestoreNoita() changed save00 player.xml to %q, %v", got, err)
+	}
+	if exists(generationPath(src, 1)) {
+		t.Error("RestoreNoita() moved save00 aside while extracting to a target")
+	}
+
+	// neither a non-empty target nor save00, its generations, its undo copy or the backups
+	// are accepted
+	for _, target := range []string{
+		target,
+		src,
+		filepath.Join(src, "sandbox"),
+		filepath.Join(generationPath(src, 1), "sandbox"),
+		filepath.Join(generationPath(src, 12), "sandbox"),
+		filepath.Join(src+backupSuffix, "sandbox"),
+		filepath.Join(src+undoSuffix, "sandbox"),
+		dst,
+		filepath.Join(dst, "sandbox"),
+	} {
+		restore := NewRestore(StrLatest, NewBackup(false, false, 16, src, dst, fakeProcessDetector{}))
+		restore.Target = target
+		if err := restore.restoreNoita(); err == nil || !strings.Contains(err.Error(), ErrExtractingBackup) {
+			t.Errorf("restoreNoita() to %s = %v, expected %q", target, err, ErrExtractingBackup)
+		}
+	}
+	if got, err := os.ReadFile(filepath.Join(src, "player.xml")); err != nil || string(got) != "live" {
+		t.Errorf("restoreNoita() changed save00 player.xml to %q, %v", got, err)
+	}
+
+	// siblings that merely share a prefix with save00 are fine
+	for _, target := range []string{src + "-inspect", src + backupSuffix + "ery", generationPath(src, 1) + "-old"} {
+		restore := NewRestore(StrLatest, NewBackup(false, false, 16, src, dst, fakeProcessDetector{}))
+		restore.Target = target
+		if err := restore.restoreNoita(); err != nil {
+			t.Errorf("restoreNoita() to %s = %v, expected the backup extracted", target, err)
+		}
+	}
+}
+
+func TestWatcher_SaveScum(t *testing.T) {
+	viper.Set(ViperNumWorkers, 2)
+	defer viper.Set(ViperNumWorkers, nil)
+
+	src := filepath.Join(t.TempDir(), "save00")
+	dst := t.TempDir()
+	sessions := filepath.Join(src, "stats", "sessions")
+	if err := os.MkdirAll(sessions, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "player.xml"), []byte("dead"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	id := time.Now().Add(-time.Hour).Format(TimeFormat)
+	if err := os.MkdirAll(filepath.Join(dst, id), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, id, "player.xml"), []byte("alive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	detector := &fakeProcessDetector{}
+	w := NewWatcher(detector, func() *Backup {
+		return NewBackup(false, false, 16, src, dst, detector)
+	}, time.Second, 0, 0, false)
+	w.SaveScum = SaveScumAuto
+
+	start := time.Now().Add(-time.Minute)
+	detector.running = true
+	w.poll(start)
+
+	stats := `<Stats dead="1" killed_by="acid | " death_pos.x="0" death_pos.y="0"></Stats>`
+	if err := os.WriteFile(filepath.Join(sessions, "20240612-174918_stats.xml"), []byte(stats), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	detector.running = false
+	if w.poll(start.Add(time.Second)) {
+		t.Error("poll() backed up the run that ended in death")
+	}
+
+	got, err := os.ReadFile(filepath.Join(src, "player.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "alive" {
+		t.Errorf("restored player.xml = %q, expected the backup taken before the death", got)
+	}
+
+	record, err := os.ReadFile(filepath.Join(dst, saveScumLogName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(record), id) || !strings.Contains(string(record), "killed by acid") {
+		t.Errorf("%s = %q, expected the restored backup and the cause of death", saveScumLogName, record)
+	}
+}
+
+func TestWatcher_SaveScumFailed(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "save00")
+	dst := t.TempDir()
+	id := time.Now().Add(-time.Hour).Format(TimeFormat)
+	writeFiles(t, map[string]string{
+		filepath.Join(src, "player.xml"):     "dead",
+		filepath.Join(dst, id, "player.xml"): "alive",
+	})
+	// a pending restore journal makes the save-scum restore fail
+	if err := writeJournal(dst, &RestoreJournal{Backup: id, Source: src}, JournalCopying); err != nil {
+		t.Fatal(err)
+	}
+
+	detector := &fakeProcessDetector{}
+	w := NewWatcher(detector, func() *Backup {
+		return NewBackup(false, false, 16, src, dst, detector)
+	}, time.Second, 0, 0, false)
+	w.SaveScum = SaveScumAuto
+
+	start := time.Now().Add(-time.Minute)
+	detector.running = true
+	w.poll(start)
+
+	stats := `<Stats dead="1" killed_by="acid | " death_pos.x="0" death_pos.y="0"></Stats>`
+	writeFiles(t, map[string]string{filepath.Join(src, "stats", "sessions", "20240612-174918_stats.xml"): stats})
+
+	detector.running = false
+	if !w.poll(start.Add(time.Second)) {
+		t.Error("poll() did not back up after the save-scum restore failed")
+	}
+	if got := readFile(filepath.Join(src, "player.xml")); got != "dead" {
+		t.Errorf("player.xml = %q, expected the save of the run that ended in death", got)
+	}
+	if exists(filepath.Join(dst, saveScumLogName)) {
+		t.Errorf("%s was written for a failed restore", saveScumLogName)
+	}
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy is a grandfather-father-son policy, every field is the number of
+// most recent backups, hours, days, weeks and months to keep a backup for.
+type RetentionPolicy struct {
+	Last    int
+	Hourly  int
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+// RetentionDecision records whether a backup is kept, along with every rule that keeps it
+// or, for a removed backup, why no rule does.
+type RetentionDecision struct {
+	ID        string   `json:"id"`
+	Keep      bool     `json:"keep"`
+	Reasons   []string `json:"reasons,omitempty"`
+	timestamp time.Time
+}
+
+type retentionRule struct {
+	name   string
+	keep   int
+	bucket func(t time.Time) string
+}
+
+func getRetentionPolicy() RetentionPolicy {
+	return RetentionPolicy{
+		Last:    viper.GetInt(ViperKeepLast),
+		Hourly:  viper.GetInt(ViperKeepHourly),
+		Daily:   viper.GetInt(ViperKeepDaily),
+		Weekly:  viper.GetInt(ViperKeepWeekly),
+		Monthly: viper.GetInt(ViperKeepMonthly),
+	}
+}
+
+// Enabled reports whether any rule is set, otherwise the newest num-backups are kept.
+func (p RetentionPolicy) Enabled() bool {
+	return p.Last > 0 || p.Hourly > 0 || p.Daily > 0 || p.Weekly > 0 || p.Monthly > 0
+}
+
+// applyRetention decides for every backup, sorted oldest first, whether the policy
+// keeps it.  Every time based rule keeps the newest backup of each of its most recent
+// periods that contain a backup, a removed backup is either in one of those periods
+// behind a newer backup or outside all of them.
+func applyRetention(backupDirs []time.Time, policy RetentionPolicy) []RetentionDecision {
+	rules := []retentionRule{
+		{InfoKeepLast, policy.Last, func(t time.Time) string { return t.Format(TimeFormat) }},
+		{InfoKeepHourly, policy.Hourly, func(t time.Time) string { return t.Format("2006-01-02 15h") }},
+		{InfoKeepDaily, policy.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{InfoKeepWeekly, policy.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{InfoKeepMonthly, policy.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+
+	decisions := make([]RetentionDecision, len(backupDirs))
+	kept := make([]map[string]bool, len(rules))
+	for r, rule := range rules {
+		kept[r] = make(map[string]bool)
+		for i := len(backupDirs) - 1; i >= 0 && len(kept[r]) < rule.keep; i-- {
+			bucket := rule.bucket(backupDirs[i])
+			if kept[r][bucket] {
+				continue
+			}
+			kept[r][bucket] = true
+
+			decisions[i].Keep = true
+			decisions[i].Reasons = append(decisions[i].Reasons, fmt.Sprintf("%s %s", rule.name, bucket))
+		}
+	}
+
+	for i, backupDir := range backupDirs {
+		decisions[i].ID = backupDir.Format(TimeFormat)
+		decisions[i].timestamp = backupDir
+		if decisions[i].Keep {
+			continue
+		}
+
+		// every rule in use explains why it does not keep this backup
+		for r, rule := range rules {
+			if rule.keep <= 0 {
+				continue
+			}
+			if bucket := rule.bucket(backupDir); kept[r][bucket] {
+				decisions[i].Reasons = append(decisions[i].Reasons, fmt.Sprintf(InfoSuperseded, rule.name, bucket))
+			} else {
+				decisions[i].Reasons = append(decisions[i].Reasons, fmt.Sprintf(InfoOutsideKeep, rule.name, rule.keep))
+			}
+		}
+	}
+
+	return decisions
+}
+
+// Prune applies the retention policy, or keeps the newest num-backups when no policy
+// is set, and removes every backup that is not kept unless dryRun is set.
+func (b *Backup) Prune(dryRun bool) ([]RetentionDecision, error) {
+	var err error
+	b.sortedBackupDirs, err = getBackupDirs(b.dstPath, TimeFormat)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrErrorGettingBackups, err)
+	}
+
+	policy := getRetentionPolicy()
+	if !policy.Enabled() {
+		if b.maxBackups <= 0 {
+			return nil, fmt.Errorf(ErrInvalidBackups)
+		}
+		policy = RetentionPolicy{Last: b.maxBackups}
+	}
+
+	// pinned backups are always kept and do not take part in the policy
+	pinned, unpinned := splitPinned(b.dstPath, b.sortedBackupDirs)
+	decisions := applyRetention(unpinned, policy)
+	for _, backupDir := range pinned {
+		decisions = append(decisions, RetentionDecision{
+			ID:        backupDir.Format(TimeFormat),
+			Keep:      true,
+			Reasons:   []string{InfoPinned},
+			timestamp: backupDir,
+		})
+	}
+	sort.Slice(decisions, func(i, j int) bool { return decisions[i].timestamp.Before(decisions[j].timestamp) })
+
+	if dryRun {
+		return decisions, nil
+	}
+
+	return decisions, b.removeBackups(decisions)
+}
+
+func (b *Backup) removeBackups(decisions []RetentionDecision) error {
+	for _, decision := range decisions {
+		if decision.Keep {
+			continue
+		}
+
+		folder := getBackupPath(b.dstPath, decision.timestamp)
+		b.LogRing.LogAndAppend(fmt.Sprintf("%s: %s", InfoRemovingBackup, folder))
+		if err := os.RemoveAll(folder); err != nil {
+			return err
+		}
+	}
+
+	return b.collectObjects()
+}
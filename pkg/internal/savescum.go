@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SaveScumOff       = "off"
+	SaveScumAuto      = "auto"
+	SaveScumPrompt    = "prompt"
+	saveScumLogName   = "savescum.log"
+	sessionsDir       = "stats/sessions"
+	sessionStatsMatch = "*_stats.xml"
+)
+
+var SaveScumModes = []string{SaveScumOff, SaveScumAuto, SaveScumPrompt}
+
+// SessionOutcome describes how a Noita run ended, as recorded in
+// save00/stats/sessions/<date>-<time>_stats.xml.
+type SessionOutcome struct {
+	File     string
+	Dead     bool
+	KilledBy string
+	Ended    time.Time
+}
+
+// lastSessionOutcome reads the stats of the newest session below srcPath.  The
+// session files are named by the time the run started, so the newest sorts last.
+func lastSessionOutcome(srcPath string) (SessionOutcome, error) {
+	files, err := filepath.Glob(filepath.Join(srcPath, filepath.FromSlash(sessionsDir), sessionStatsMatch))
+	if err != nil {
+		return SessionOutcome{}, err
+	}
+	if len(files) == 0 {
+		return SessionOutcome{}, fmt.Errorf(ErrNoSessions)
+	}
+	sort.Strings(files)
+
+	return readSessionOutcome(files[len(files)-1])
+}
+
+// isSessionStats reports whether the slash separated path below save00 holds the stats of a session.
+func isSessionStats(p string) bool {
+	matched, _ := path.Match(sessionsDir+"/"+sessionStatsMatch, p)
+	return matched
+}
+
+func readSessionOutcome(path string) (SessionOutcome, error) {
+	outcome := SessionOutcome{File: filepath.Base(path)}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return outcome, err
+	}
+	defer func(f *os.File) {
+		err := f.Close()
+		if err != nil {
+			log.Printf("%s: %v", ErrClosingFile, err)
+		}
+	}(f)
+
+	info, err := f.Stat()
+	if err != nil {
+		return outcome, err
+	}
+	outcome.Ended = info.ModTime()
+
+	// the outcome is stored as attributes of the root <Stats> element
+	decoder := xml.NewDecoder(f)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return outcome, fmt.Errorf("%s: %s", ErrReadingSession, path)
+		}
+		if err != nil {
+			return outcome, fmt.Errorf("%s: %v", ErrReadingSession, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "dead":
+				outcome.Dead = attr.Value == "1" || strings.EqualFold(attr.Value, "true")
+			case "killed_by":
+				outcome.KilledBy = strings.TrimSpace(strings.Trim(attr.Value, "| "))
+			}
+		}
+		return outcome, nil
+	}
+}
+
+// preDeathBackup returns the newest backup in backupPath taken before the session
+// that ended in death.
+func preDeathBackup(backupPath string, outcome SessionOutcome) (time.Time, error) {
+	backupDirs, err := getBackupDirs(backupPath, TimeFormat)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for i := len(backupDirs) - 1; i >= 0; i-- {
+		if localTime(backupDirs[i]).Before(outcome.Ended) {
+			return backupDirs[i], nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf(ErrNoPreDeathBackup, outcome.File)
+}
+
+// recordSaveScum appends the backup restored after a death to the save-scum log
+// kept next to the backups.
+func recordSaveScum(backupPath, id string, outcome SessionOutcome) error {
+	f, err := os.OpenFile(filepath.Join(backupPath, saveScumLogName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		err := f.Close()
+		if err != nil {
+			log.Printf("%s: %v", ErrClosingFile, err)
+		}
+	}(f)
+
+	_, err = fmt.Fprintf(f, "%s restored %s after death in %s (killed by %s)\n",
+		time.Now().Format(TimeFormat), id, outcome.File, outcome.KilledBy)
+	return err
+}
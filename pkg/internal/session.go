@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultSessionPollInterval = 2 * time.Second
+	DefaultSessionStartTimeout = 2 * time.Minute
+)
+
+// Session runs a supervised play session: it optionally restores a backup, launches
+// Noita through Steam, waits for the game to start and exit and then backs up.
+type Session struct {
+	Backup       *Backup
+	RestoreFile  string
+	BackupOnExit bool
+	PollInterval time.Duration
+	StartTimeout time.Duration
+	phase        int
+}
+
+// NewSession creates a Session for backup.  The session launches Noita itself, so
+// auto-launch is turned off for the restore and backup it runs.
+func NewSession(backup *Backup, restoreFile string, backupOnExit bool) *Session {
+	backup.autoLaunchChecked = false
+	return &Session{
+		Backup:       backup,
+		RestoreFile:  restoreFile,
+		BackupOnExit: backupOnExit,
+		PollInterval: DefaultSessionPollInterval,
+		StartTimeout: DefaultSessionStartTimeout,
+	}
+}
+
+func (s *Session) Run() error {
+	s.phase = started
+	defer func() { s.phase = stopped }()
+
+	b := s.Backup
+	if process, running := b.detector.NoitaProcess(); running {
+		return fmt.Errorf(noitaRunningError(process, ErrDuringSession))
+	}
+
+	if s.RestoreFile != "" {
+		// keep progress no backup holds yet instead of refusing to restore
+		restore := NewRestore(s.RestoreFile, b)
+		restore.BackupFirst = true
+		if err := restore.restoreNoita(); err != nil {
+			return err
+		}
+	}
+
+	if err := LaunchNoita(true, b.detector); err != nil {
+		return fmt.Errorf("%s: %v", ErrLaunchingNoita, err)
+	}
+	if !s.BackupOnExit {
+		return nil
+	}
+
+	// steam returns right away, the game itself shows up a little later
+	b.LogRing.LogAndAppend(InfoWaitingForStart)
+	deadline := time.Now().Add(s.StartTimeout)
+	process, running := b.detector.NoitaProcess()
+	for !running {
+		if time.Now().After(deadline) {
+			return fmt.Errorf(ErrNoitaDidNotStart, s.StartTimeout)
+		}
+		time.Sleep(s.PollInterval)
+		process, running = b.detector.NoitaProcess()
+	}
+	b.LogRing.LogAndAppend(fmt.Sprintf(InfoNoitaStarted, process))
+
+	b.LogRing.LogAndAppend(InfoWaitingForExit)
+	for running {
+		time.Sleep(s.PollInterval)
+		_, running = b.detector.NoitaProcess()
+	}
+	b.LogRing.LogAndAppend(InfoNoitaExited)
+
+	return b.backupNoita()
+}
+
+func (s *Session) isRunning() bool {
+	return s.phase == started
+}
@@ -2,6 +2,7 @@ package internal
 
 const (
 	StrLatest = "latest"
+	StrLive   = "live"
 )
 
 const (
@@ -9,6 +10,7 @@ const (
 	ErrInvalidBackups             = "max backups must be greater than zero"
 	ErrOperationAlreadyInProgress = "operation already in progress"
 	ErrNoitaRunning               = "noita.exe cannot be running"
+	ErrNoTerminal                 = "no terminal to ask whether to roll it forward or back"
 	ErrErrorGettingBackups        = "error getting backups"
 	ErrFailureDeletingBackups     = "failure deleting backups"
 	ErrFailedToLaunch             = "failed to launch noita"
@@ -16,12 +18,26 @@ const (
 	ErrBackupNotFound             = "backup %s not found in backup directory"
 	ErrCannotCreateDestination    = "cannot create destination path"
 	ErrDuringRestore              = "during restore"
+	ErrDuringUndo                 = "during undo-restore"
+	ErrNoGeneration               = "no %s to undo the restore with"
+	ErrGenerations                = "restore generations must be greater than zero"
+	ErrReadingJournal             = "error reading restore journal"
+	ErrWritingJournal             = "error writing restore journal"
+	ErrRecoveringRestore          = "error recovering interrupted restore"
+	ErrRestorePending             = "roll the interrupted restore forward or back first"
+	ErrUnsavedProgress            = "save00 has progress newer than the newest backup, back it up first or force the restore"
+	ErrBackupFirst                = "error backing up save00 before restoring"
+	ErrBackupRemoved              = "the backup to restore was removed to make room"
 	ErrDuringBackup               = "during backup"
+	ErrDuringSession              = "during launch"
+	ErrNoitaDidNotStart           = "noita.exe did not start within %s"
+	ErrSessionFailed              = "play session failed"
 	ErrFailedGettingBackupDirs    = "failed to get backup dirs"
 	ErrNoBackupDirs               = "no backup dirs found, cannot restore"
 	ErrRestoringToSave00          = "error restoring backup file to save00"
-	ErrCopyingToSave00            = "error copying latest backup %s to save00: %v"
+	ErrCopyingToSave00            = "error copying backup to save00"
 	ErrLaunchingExplorer          = "error launching explorer"
+	ErrHomeDir                    = "error finding home directory"
 	ErrLaunchingNoita             = "error launching noita"
 	ErrNumBackups                 = "number of backups to keep must be between 1 and 64"
 	ErrNumWorkers                 = "number of workers must be between 1 and 32"
@@ -34,6 +50,44 @@ const (
 	ErrStatFile                   = "error reading file or directory"
 	ErrCopyFile                   = "error copying file"
 	ErrWorkerFailed               = "worker error"
+	ErrCreatingArchive            = "error creating backup archive"
+	ErrInvalidArchiveEntry        = "invalid archive entry"
+	ErrInvalidFormat              = "backup format must be one of: dir, zip, repo"
+	ErrHashingFile                = "error hashing file"
+	ErrStoringObject              = "error storing object"
+	ErrObjectMismatch             = "object does not match its hash"
+	ErrLinkFile                   = "error linking file"
+	ErrWritingManifest            = "error writing manifest"
+	ErrReadingManifest            = "error reading manifest"
+	ErrOpeningBackup              = "error opening backup"
+	ErrBackupCorrupt              = "backup failed verification"
+	ErrVerifyFailed               = "verification failed"
+	ErrVerifyAllWithID            = "--all cannot be combined with a backup id"
+	ErrFinalizingBackup           = "error moving completed backup into place"
+	ErrQuarantiningStaging        = "error quarantining interrupted backups"
+	ErrKeepNegative               = "retention keep values cannot be negative"
+	ErrPruneFailed                = "prune failed"
+	ErrPinningBackup              = "error changing backup pin"
+	ErrInvalidSelector            = "invalid backup selector %s, expected latest~N"
+	ErrInvalidTimeFilter          = "invalid time %s, expected a duration such as 24h or a date such as 2024-06-12"
+	ErrInvalidFilter              = "invalid filter %s, expected perk=, biome=, orbs= or ng= followed by a value"
+	ErrListFailed                 = "list failed"
+	ErrComparingSave00            = "error comparing save00 against the latest backup"
+	ErrInvalidSaveScum            = "save-scum must be one of: off, auto, prompt"
+	ErrNoSessions                 = "no sessions recorded in stats/sessions"
+	ErrReadingSession             = "error reading session stats"
+	ErrNoPreDeathBackup           = "no backup taken before the session %s"
+	ErrRecordingSaveScum          = "error recording save-scum restore"
+	ErrSaveScumFailed             = "error restoring the backup taken before the death"
+	ErrInspectingSave             = "error inspecting save"
+	ErrDiffFailed                 = "diff failed"
+	ErrExtractingBackup           = "error extracting backup"
+	ErrTargetIsSave00             = "target %s is the live save, use restore without --target"
+	ErrTargetNotEmpty             = "target %s is not empty"
+	ErrTargetInBackups            = "target %s is inside the backup directory"
+	ErrNoExtractTarget            = "enter a directory to extract the backup to"
+	ErrReadingProgress            = "error reading unlock flags"
+	ErrInvalidInterval            = "poll interval must be greater than 0"
 )
 
 // Info
@@ -47,17 +101,69 @@ const (
 	InfoTotalDirCopied    = "total dirs copied"
 	InfoTotalFileCopied   = "total files copied"
 	InfoCreatingSave00    = "creating save00 directory"
-	InfoCopyBackup        = "copying latest backup %s to save00"
+	InfoCopyBackup        = "copying backup %s to %s"
 	InfoSuccessfulRestore = "successfully restored backup"
-	InfoDeletingSave00Bak = "deleting save00.bak folder"
-	InfoRename            = "renaming save00 to save00.bak"
-	InfoRenameRestore     = "renaming save00.bak to save00"
+	InfoSuccessfulExtract = "successfully extracted backup to"
+	InfoRename            = "renaming save00 to %s"
+	InfoRenameRestore     = "renaming %s to save00"
+	InfoMigratingBak      = "migrating %s to the first emergency generation"
+	InfoUndoRestore       = "swapped %s with %s"
+	InfoInterrupted       = "restore of backup %s into %s was interrupted at %s"
+	InfoRollForward       = "roll forward by copying the backup into save00 again?"
+	InfoRollBack          = "roll back by putting save00.bak.1 back in place of save00?"
+	InfoRolledForward     = "finished interrupted restore into"
+	InfoRolledBack        = "rolled back interrupted restore of"
+	InfoJournalKept       = "leaving the interrupted restore alone until the next start"
+	InfoUnsavedProgress   = "save00 changed %s with %d sessions, newer than the newest backup %s changed %s with %d sessions"
+	InfoRestorePreview    = "restoring backup %s replaces %d, adds %d and removes %d files, copying %s into %s"
 	InfoDebugLogSet       = "debug log set to"
 	InfoAutoLaunchSet     = "auto-launch set to"
 	InfoStartingRestore   = "starting restore"
 	InfoStartingBackup    = "starting backup"
 	InfoErrorMessage      = "Configuration Error:"
 	InfoDeletePath        = "deleting path: %s"
+	InfoRemovedObjects    = "removed unreferenced objects"
+	InfoLinkDest          = "hardlinking unchanged files against"
+	InfoNoManifest        = "backup has no manifest, skipping verification"
+	InfoVerifyOK          = "ok"
+	InfoVerifyUnverified  = "unverifiable, no manifest or file list was recorded"
+	InfoVerifyMissing     = "missing"
+	InfoVerifyExtra       = "extra"
+	InfoVerifyTruncated   = "truncated"
+	InfoVerifyMismatched  = "hash mismatch"
+	InfoQuarantined       = "quarantined interrupted backup"
+	InfoPurgedBackup      = "removed quarantined backup"
+	InfoQuarantineHolds   = "%d interrupted backups are kept in %s"
+	InfoKeepLast          = "last"
+	InfoKeepHourly        = "hourly"
+	InfoKeepDaily         = "daily"
+	InfoKeepWeekly        = "weekly"
+	InfoKeepMonthly       = "monthly"
+	InfoOutsideKeep       = "outside keep-%s %d"
+	InfoSuperseded        = "%s %s has a newer backup"
+	InfoKeep              = "keep"
+	InfoRemove            = "remove"
+	InfoPinned            = "pinned"
+	InfoUnpinned          = "unpinned"
+	InfoWatching          = "watching noita.exe every %s, backing up %s after it exits at most every %s"
+	InfoNoitaStarted      = "noita.exe started (%s)"
+	InfoNoitaExited       = "noita.exe exited"
+	InfoRestoringSave     = "restoring backup %s: %s"
+	InfoLatestBackup      = "latest backup %s: %s"
+	InfoNoBackups         = "no backups yet"
+	InfoNoMetadata        = "no player recorded for this backup"
+	InfoInventorySpells   = "inventory spells"
+	InfoUnlocks           = "unlocks"
+	InfoGained            = "gained"
+	InfoLost              = "lost"
+	InfoNoitaDied         = "noita.exe run ended in death, killed by %s (%s)"
+	InfoSaveScumPrompt    = "restore backup %s taken before the death?"
+	InfoSaveScumDeclined  = "keeping the save of the run that ended in death"
+	InfoWaitingForStart   = "waiting for noita.exe to start"
+	InfoWaitingForExit    = "waiting for noita.exe to exit"
+	InfoBackupOnExitSet   = "backup on exit set to"
+	InfoSkipMinInterval   = "last backup was %s ago, skipping backup until %s passed"
+	InfoSkipUnchanged     = "save00 unchanged since the latest backup, skipping backup"
 )
 
 // Viper
@@ -68,6 +174,14 @@ const (
 	ViperSourcePath      = "source-path"
 	ViperDestinationPath = "destination-path"
 	ViperSteamPath       = "steam-path"
+	ViperBackupFormat    = "format"
+	ViperIncremental     = "incremental"
+	ViperGenerations     = "restore-generations"
+	ViperKeepLast        = "keep-last"
+	ViperKeepHourly      = "keep-hourly"
+	ViperKeepDaily       = "keep-daily"
+	ViperKeepWeekly      = "keep-weekly"
+	ViperKeepMonthly     = "keep-monthly"
 )
 
 // Buttons
@@ -77,15 +191,31 @@ const (
 	BtnRestore = "Restore Noita"
 	BtnExplore = "Explore Backups"
 	BtnQuit    = "Quit"
+	BtnPin     = "Pin Latest"
+	BtnUnpin   = "Unpin Latest"
+	BtnExtract = "Extract To"
+	BtnForward = "Roll Forward"
+	BtnBack    = "Roll Back"
+	BtnConfirm = "Confirm"
+	BtnCancel  = "Cancel"
+	BtnFirst   = "Backup First"
+	BtnDiscard = "Discard"
 )
 
 // Checkbox
 const (
 	ChkAutoLaunch = "Auto Launch"
 	ChkDebugLog   = "Debug Log"
+	ChkExitBackup = "Backup On Exit"
 )
 
 const (
 	SldNumBackupsToKeep = "Number backups to keep"
 	SldNumWorkers       = "Number concurrent workers"
 )
+
+// Editor
+const (
+	EdtExtractBackup = "Backup, latest by default"
+	EdtExtractTarget = "Directory to extract the backup to"
+)
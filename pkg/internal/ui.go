@@ -11,13 +11,14 @@ import (
 	"gioui.org/widget/material"
 	"github.com/spf13/viper"
 	"image/color"
+	"strings"
 )
 
 const (
 	stopped int = iota
 	started
-	DefaultMinHeight = 155
-	DefaultMaxHeight = 580
+	DefaultMinHeight = 303
+	DefaultMaxHeight = 728
 	DefaultWidth     = 640
 	ErrorWidth       = DefaultWidth
 	ErrorHeight      = 280
@@ -31,12 +32,25 @@ var (
 	launchButton      = new(widget.Clickable)
 	backupButton      = new(widget.Clickable)
 	restoreButton     = new(widget.Clickable)
+	pinButton         = new(widget.Clickable)
+	unpinButton       = new(widget.Clickable)
+	extractButton     = new(widget.Clickable)
+	forwardButton     = new(widget.Clickable)
+	backButton        = new(widget.Clickable)
+	confirmButton     = new(widget.Clickable)
+	cancelButton      = new(widget.Clickable)
+	firstButton       = new(widget.Clickable)
+	discardButton     = new(widget.Clickable)
+	extractIDEditor   = &widget.Editor{SingleLine: true, Submit: true}
+	extractEditor     = &widget.Editor{SingleLine: true, Submit: true}
 	debugLog          = new(widget.Bool)
-	debugHeight       = 155
+	debugHeight       = DefaultMinHeight
 	autoLaunch        = new(widget.Bool)
+	exitBackup        = new(widget.Bool)
 	numBackups        = new(widget.Float)
 	numWorkers        = new(widget.Float)
 	autoLaunchChecked = false
+	exitBackupChecked = false
 	debugLogChecked   = false
 	list              = &widget.List{
 		List: layout.List{
@@ -53,15 +67,25 @@ type (
 type UI struct {
 	backup            *Backup
 	restore           *Restore
+	session           *Session
 	Logger            *LogRing
 	autoLaunchChecked bool
+	detector          ProcessDetector
+	latestBackup      string
+	journal           *RestoreJournal
+	preview           *RestorePreview
+	previews          chan *RestorePreview
+	wasRunning        bool
 	theme             *material.Theme
+	window            *app.Window
 }
 
-func NewUI(autoLaunch bool) *UI {
+func NewUI(autoLaunch bool, detector ProcessDetector) *UI {
 	return &UI{
 		Logger:            NewLogRing(16),
 		autoLaunchChecked: autoLaunch,
+		detector:          detector,
+		previews:          make(chan *RestorePreview, 1),
 	}
 }
 
@@ -69,8 +93,11 @@ func NewUI(autoLaunch bool) *UI {
 // It takes a *app.Window as a parameter and returns an error if any.
 func (ui *UI) Run(window *app.Window) error {
 	ui.theme = material.NewTheme()
+	ui.window = window
 	var ops op.Ops
 	autoLaunch.Value, autoLaunchChecked = ui.autoLaunchChecked, ui.autoLaunchChecked
+	ui.refreshLatestBackup()
+	ui.readJournal()
 
 	for {
 		switch e := window.Event().(type) {
@@ -106,6 +133,19 @@ func (ui *UI) Run(window *app.Window) error {
 				ui.Logger.LogAndAppend(fmt.Sprintf("%s %t", InfoAutoLaunchSet, autoLaunchChecked))
 			}
 
+			// describe the latest backup again once a backup or restore finished
+			if running := ui.isOperationRunning(); running != ui.wasRunning {
+				ui.wasRunning = running
+				if !running {
+					ui.refreshLatestBackup()
+				}
+			}
+
+			if exitBackup.Update(gtx) {
+				exitBackupChecked = !exitBackupChecked
+				ui.Logger.LogAndAppend(fmt.Sprintf("%s %t", InfoBackupOnExitSet, exitBackupChecked))
+			}
+
 			for exploreButton.Clicked(gtx) {
 				err := LaunchExplorer()
 				if err != nil {
@@ -114,24 +154,80 @@ func (ui *UI) Run(window *app.Window) error {
 			}
 
 			for launchButton.Clicked(gtx) {
-				if !ui.isOperationRunning() {
-					err := LaunchNoita(true)
+				if ui.isOperationRunning() {
+					ui.Logger.LogAndAppend(ErrOperationAlreadyInProgress)
+				} else if exitBackupChecked {
+					ui.runSession()
+				} else {
+					err := LaunchNoita(true, ui.detector)
 					if err != nil {
 						ui.Logger.LogAndAppend(fmt.Sprintf("%s: %v", ErrLaunchingNoita, err))
 					}
-				} else {
-					ui.Logger.LogAndAppend(ErrOperationAlreadyInProgress)
 				}
 			}
 
 			for restoreButton.Clicked(gtx) {
 				if !ui.isOperationRunning() {
-					ui.runRestore()
+					ui.previewRestore()
 				} else {
 					ui.Logger.LogAndAppend(ErrOperationAlreadyInProgress)
 				}
 			}
 
+			// a preview finished in the background is picked up on the frame it asked for
+			select {
+			case ui.preview = <-ui.previews:
+			default:
+			}
+
+			for confirmButton.Clicked(gtx) {
+				ui.confirmRestore(false, false)
+			}
+
+			for firstButton.Clicked(gtx) {
+				ui.confirmRestore(false, true)
+			}
+
+			for discardButton.Clicked(gtx) {
+				ui.confirmRestore(true, false)
+			}
+
+			for cancelButton.Clicked(gtx) {
+				ui.preview = nil
+			}
+
+			for forwardButton.Clicked(gtx) {
+				ui.recoverRestore(true)
+			}
+
+			for backButton.Clicked(gtx) {
+				ui.recoverRestore(false)
+			}
+
+			for extractButton.Clicked(gtx) {
+				ui.runExtract()
+			}
+
+			for _, editor := range []*widget.Editor{extractIDEditor, extractEditor} {
+				for {
+					event, ok := editor.Update(gtx)
+					if !ok {
+						break
+					}
+					if _, ok := event.(widget.SubmitEvent); ok {
+						ui.runExtract()
+					}
+				}
+			}
+
+			for pinButton.Clicked(gtx) {
+				ui.pinLatest(true)
+			}
+
+			for unpinButton.Clicked(gtx) {
+				ui.pinLatest(false)
+			}
+
 			for backupButton.Clicked(gtx) {
 				if !ui.isOperationRunning() {
 					ui.runBackup()
@@ -141,7 +237,7 @@ func (ui *UI) Run(window *app.Window) error {
 			}
 
 			// TODO: make this not run every frame!
-			if isNoitaRunning() {
+			if _, running := ui.detector.NoitaProcess(); running {
 				paint.ColorOp{Color: color.NRGBA{A: 0xff, R: 0xff}}.Add(gtx.Ops)
 			} else {
 				paint.ColorOp{Color: color.NRGBA{A: 0xff, G: 0xff}}.Add(gtx.Ops)
@@ -159,6 +255,58 @@ func (ui *UI) Run(window *app.Window) error {
 						)
 					})
 				},
+				func(gtx C) D {
+					return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+						return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+							ui.makeButton(pinButton, BtnPin),
+							ui.makeButton(unpinButton, BtnUnpin),
+							layout.Rigid(func(gtx C) D {
+								return layout.UniformInset(unit.Dp(8)).Layout(gtx, material.CheckBox(ui.theme, exitBackup, ChkExitBackup).Layout)
+							}),
+						)
+					})
+				},
+				func(gtx C) D {
+					// an interrupted restore takes the place of the latest backup until it is recovered
+					if ui.journal.Incomplete() {
+						return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+							layout.Flexed(1, func(gtx C) D {
+								return layout.UniformInset(unit.Dp(8)).Layout(gtx, material.Body1(ui.theme, ui.journal.String()).Layout)
+							}),
+							ui.makeButton(forwardButton, BtnForward),
+							ui.makeButton(backButton, BtnBack),
+						)
+					}
+					// a restore waits here for confirmation once its preview is shown
+					if ui.preview != nil {
+						summary := ui.preview.String()
+						buttons := []layout.FlexChild{ui.makeButton(confirmButton, BtnConfirm)}
+						if ui.preview.Unsaved != nil {
+							// newer progress is only thrown away when explicitly discarded
+							summary = fmt.Sprintf("%s, %s", summary, ui.preview.Unsaved)
+							buttons = []layout.FlexChild{ui.makeButton(firstButton, BtnFirst), ui.makeButton(discardButton, BtnDiscard)}
+						}
+						buttons = append(buttons, ui.makeButton(cancelButton, BtnCancel))
+
+						return layout.Flex{Alignment: layout.Middle}.Layout(gtx, append([]layout.FlexChild{
+							layout.Flexed(1, func(gtx C) D {
+								return layout.UniformInset(unit.Dp(8)).Layout(gtx, material.Body1(ui.theme, summary).Layout)
+							}),
+						}, buttons...)...)
+					}
+					return layout.UniformInset(unit.Dp(8)).Layout(gtx, material.Body1(ui.theme, ui.latestBackup).Layout)
+				},
+				func(gtx C) D {
+					return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+						layout.Flexed(1, func(gtx C) D {
+							return layout.UniformInset(unit.Dp(8)).Layout(gtx, material.Editor(ui.theme, extractIDEditor, EdtExtractBackup).Layout)
+						}),
+						layout.Flexed(2, func(gtx C) D {
+							return layout.UniformInset(unit.Dp(8)).Layout(gtx, material.Editor(ui.theme, extractEditor, EdtExtractTarget).Layout)
+						}),
+						ui.makeButton(extractButton, BtnExtract),
+					)
+				},
 				func(gtx C) D {
 					ui.updateLoadFunc()
 
@@ -237,22 +385,88 @@ func (ui *UI) makeButton(button *widget.Clickable, label string) layout.FlexChil
 	})
 }
 
-func (ui *UI) runRestore() {
+// previewRestore runs the checks of a restore of the latest backup in the background and
+// shows what it would change once done, the restore itself only starts once confirmed.
+func (ui *UI) previewRestore() {
 	ui.restore = NewRestore(
 		StrLatest,
+		NewBackup(
+			false,
+			false,
+			viper.GetInt(ViperNumBackups),
+			viper.GetString(ViperSourcePath),
+			viper.GetString(ViperDestinationPath),
+			ui.detector,
+		),
+	)
+	ui.restore.DryRun = true
+	ui.restore.Backup.LogRing = ui.Logger
+
+	restore := ui.restore
+	go func() {
+		restore.RestoreNoita()
+		ui.previews <- restore.Preview
+		ui.window.Invalidate()
+	}()
+}
+
+// confirmRestore starts the previewed restore.  Newer progress in save00 shown in the
+// preview is either backed up first or, when force is set, knowingly discarded.
+func (ui *UI) confirmRestore(force, backupFirst bool) {
+	preview := ui.preview
+	ui.preview = nil
+	if preview == nil {
+		return
+	}
+	if ui.isOperationRunning() {
+		ui.Logger.LogAndAppend(ErrOperationAlreadyInProgress)
+		return
+	}
+
+	ui.runRestore(preview.Backup, "", force, backupFirst)
+}
+
+// runRestore restores the backup id to save00, or extracts it to target when set.  Newer
+// progress in save00 is backed up first or discarded when forced, the restore refuses
+// otherwise.
+func (ui *UI) runRestore(id, target string, force, backupFirst bool) {
+	ui.restore = NewRestore(
+		id,
 		NewBackup(
 			true,
 			autoLaunchChecked,
 			viper.GetInt(ViperNumBackups),
 			viper.GetString(ViperSourcePath),
 			viper.GetString(ViperDestinationPath),
+			ui.detector,
 		),
 	)
+	ui.restore.Target = target
+	ui.restore.Force, ui.restore.BackupFirst = force, backupFirst
 	ui.restore.Backup.LogRing = ui.Logger
 	ui.Logger.LogAndAppend(InfoStartingRestore)
 	ui.restore.RestoreNoita()
 }
 
+// runExtract extracts the backup entered in the editors, the latest when left empty, to
+// the directory entered next to it.
+func (ui *UI) runExtract() {
+	id := strings.TrimSpace(extractIDEditor.Text())
+	if id == "" {
+		id = StrLatest
+	}
+
+	target := strings.TrimSpace(extractEditor.Text())
+	switch {
+	case target == "":
+		ui.Logger.LogAndAppend(ErrNoExtractTarget)
+	case ui.isOperationRunning():
+		ui.Logger.LogAndAppend(ErrOperationAlreadyInProgress)
+	default:
+		ui.runRestore(id, target, false, false)
+	}
+}
+
 func (ui *UI) runBackup() {
 	ui.backup = NewBackup(
 		true,
@@ -260,12 +474,116 @@ func (ui *UI) runBackup() {
 		viper.GetInt(ViperNumBackups),
 		viper.GetString(ViperSourcePath),
 		viper.GetString(ViperDestinationPath),
+		ui.detector,
 	)
 	ui.backup.LogRing = ui.Logger
 	ui.Logger.LogAndAppend(InfoStartingBackup)
 	ui.backup.BackupNoita()
 }
 
+// refreshLatestBackup describes the latest backup and the save it holds.
+func (ui *UI) refreshLatestBackup() {
+	backupPath := viper.GetString(ViperDestinationPath)
+	backupDirs, err := getBackupDirs(backupPath, TimeFormat)
+	if err != nil || len(backupDirs) == 0 {
+		ui.latestBackup = InfoNoBackups
+		return
+	}
+
+	latest := backupDirs[len(backupDirs)-1]
+	ui.latestBackup = latest.Format(TimeFormat)
+	if metadata := readBackupMetadata(getBackupPath(backupPath, latest)); metadata != nil {
+		ui.latestBackup = fmt.Sprintf(InfoLatestBackup, latest.Format(TimeFormat), metadata)
+	}
+}
+
+// readJournal looks for a restore into save00 that was interrupted by a crash.
+func (ui *UI) readJournal() {
+	journal, err := ReadRestoreJournal(viper.GetString(ViperDestinationPath))
+	if err != nil {
+		ui.Logger.LogAndAppend(err.Error())
+		return
+	}
+
+	ui.journal = journal
+	if ui.journal.Incomplete() {
+		ui.Logger.LogAndAppend(ui.journal.String())
+	}
+}
+
+// recoverRestore rolls the interrupted restore forward or back.
+func (ui *UI) recoverRestore(forward bool) {
+	if !ui.journal.Incomplete() {
+		return
+	}
+	if ui.isOperationRunning() {
+		ui.Logger.LogAndAppend(ErrOperationAlreadyInProgress)
+		return
+	}
+	if process, running := ui.detector.NoitaProcess(); running {
+		ui.Logger.LogAndAppend(noitaRunningError(process, ErrDuringRestore))
+		return
+	}
+
+	var err error
+	if forward {
+		err = ui.journal.RollForward(ui.Logger, viper.GetString(ViperDestinationPath))
+	} else {
+		err = ui.journal.RollBack(ui.Logger, viper.GetString(ViperDestinationPath))
+	}
+	if err != nil {
+		ui.Logger.LogAndAppend(err.Error())
+		return
+	}
+
+	ui.journal = nil
+	ui.refreshLatestBackup()
+}
+
+// runSession launches Noita and backs up once the game exits.
+func (ui *UI) runSession() {
+	ui.session = NewSession(
+		NewBackup(
+			false,
+			false,
+			viper.GetInt(ViperNumBackups),
+			viper.GetString(ViperSourcePath),
+			viper.GetString(ViperDestinationPath),
+			ui.detector,
+		),
+		"",
+		true,
+	)
+	ui.session.Backup.LogRing = ui.Logger
+	ui.session.phase = started
+	go func() {
+		if err := ui.session.Run(); err != nil {
+			ui.Logger.LogAndAppend(fmt.Sprintf("%s: %v", ErrSessionFailed, err))
+		}
+	}()
+}
+
+func (ui *UI) pinLatest(pin bool) {
+	var id string
+	var err error
+
+	if pin {
+		id, err = PinBackup(viper.GetString(ViperDestinationPath), StrLatest)
+	} else {
+		id, err = UnpinBackup(viper.GetString(ViperDestinationPath), StrLatest)
+	}
+	if err != nil {
+		ui.Logger.LogAndAppend(fmt.Sprintf("%s: %v", ErrPinningBackup, err))
+		return
+	}
+
+	if pin {
+		ui.Logger.LogAndAppend(fmt.Sprintf("%s: %s", InfoPinned, id))
+	} else {
+		ui.Logger.LogAndAppend(fmt.Sprintf("%s: %s", InfoUnpinned, id))
+	}
+}
+
 func (ui *UI) isOperationRunning() bool {
 	running := false
 
@@ -281,6 +599,12 @@ func (ui *UI) isOperationRunning() bool {
 		}
 	}
 
+	if ui.session != nil {
+		if ui.session.isRunning() {
+			running = true
+		}
+	}
+
 	return running
 }
 
@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/spf13/viper"
 	"io"
@@ -8,21 +10,29 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 )
 
 // ConfigDefaultAppDataPath is the default path to the Noita application data folder.
+// ConfigDefaultProtonPath is the default path to the Noita application data folder inside the Proton prefix.
 // ConfigDefaultSavePath is the default folder name for saving Noita game data.
 // ConfigDefaultDstPath is the default folder name for storing Noita backup files.
 // ConfigUserProfile is the environment variable for the user profile path.
 // ConfigAppData is the environment variable for the application data path.
+// ConfigDefaultSteamPath is the default path to the Steam executable on Windows.
+// ConfigLinuxSteamPath is the default path to the Steam executable on Linux.
 // ConfigOverrideSrcPath is the environment variable for overriding the default source path for Noita backups.
 // ConfigOverrideDstPath is the environment variable for overriding the default destination path for Noita backups.
 const (
-	ConfigDefaultAppDataPath             = "..\\LocalLow\\Nolla_Games_Noita"
+	ConfigDefaultAppDataPath             = "../LocalLow/Nolla_Games_Noita"
+	ConfigDefaultProtonPath              = ".steam/steam/steamapps/compatdata/881100/pfx/drive_c/users/steamuser/AppData/LocalLow/Nolla_Games_Noita"
 	ConfigDefaultSavePath                = "save00"
 	ConfigDefaultDstPath                 = "NoitaBackups"
 	ConfigUserProfile                    = "USERPROFILE"
 	ConfigAppData                        = "APPDATA"
+	ConfigDefaultSteamPath               = "C:\\Program Files (x86)\\Steam\\steam.exe"
+	ConfigLinuxSteamPath                 = "/usr/bin/steam"
 	ConfigOverrideSrcPath                = "CONFIG_NOITA_SRC_PATH"
 	ConfigOverrideDstPath                = "CONFIG_NOITA_DST_PATH"
 	ConfigOverrideSteamPath              = "CONFIG_NOITA_STEAM_PATH"
@@ -37,6 +47,13 @@ func GetDefaultDestinationPath() string {
 	return buildDefaultDstPath()
 }
 
+func GetDefaultSteamPath() string {
+	if runtime.GOOS == "windows" {
+		return ConfigDefaultSteamPath
+	}
+	return ConfigLinuxSteamPath
+}
+
 func GetSourcePath(path string) (string, error) {
 	// check for source path override
 	srcPath := os.Getenv(ConfigOverrideSrcPath)
@@ -82,14 +99,21 @@ func GetSteamPath(path string) (string, error) {
 	return dstPath, nil
 }
 
-func buildDirectory(jobs chan Job, src, dst string, dirCounter, fileCounter *int) error {
+func buildDirectory(jobs chan Job, src, dst, linkDest, exclude string, dirCounter, fileCounter *int) error {
 	entries, err := os.ReadDir(src)
 	if err != nil {
 		return err
 	}
 	for _, entry := range entries {
+		if exclude != "" && entry.Name() == exclude {
+			continue
+		}
 		srcPath := filepath.Join(src, entry.Name())
 		dstPath := filepath.Join(dst, entry.Name())
+		linkPath := ""
+		if linkDest != "" {
+			linkPath = filepath.Join(linkDest, entry.Name())
+		}
 
 		srcInfo, err := os.Stat(srcPath)
 		if err != nil {
@@ -101,12 +125,15 @@ func buildDirectory(jobs chan Job, src, dst string, dirCounter, fileCounter *int
 			if err := createIfNotExists(dstPath, Mode0755); err != nil {
 				return err
 			}
-			if err := buildDirectory(jobs, srcPath, dstPath, dirCounter, fileCounter); err != nil {
+			if err := buildDirectory(jobs, srcPath, dstPath, linkPath, "", dirCounter, fileCounter); err != nil {
 				return err
 			}
 			*dirCounter += 1
 		default:
-			jobs <- Job{srcPath, dstPath}
+			if !isLinkCandidate(srcInfo, linkPath) {
+				linkPath = ""
+			}
+			jobs <- Job{srcPath, dstPath, linkPath}
 			*fileCounter += 1
 			continue
 		}
@@ -159,6 +186,67 @@ func copyFile(src, dst string) error {
 	return nil
 }
 
+// isLinkCandidate reports whether the file at linkPath has the same size and
+// modification time as the source file and may therefore be hardlinked.
+func isLinkCandidate(srcInfo os.FileInfo, linkPath string) bool {
+	if linkPath == "" {
+		return false
+	}
+
+	linkInfo, err := os.Stat(linkPath)
+	if err != nil || !linkInfo.Mode().IsRegular() {
+		return false
+	}
+
+	return linkInfo.Size() == srcInfo.Size() && linkInfo.ModTime().Equal(srcInfo.ModTime())
+}
+
+// linkIfIdentical hardlinks linkPath to dst when its content hashes the same as src.
+// It reports false without an error when the file has to be copied instead, which
+// includes file systems that do not support hardlinks.
+func linkIfIdentical(src, linkPath, dst string) (bool, error) {
+	srcHash, err := hashFile(src)
+	if err != nil {
+		return false, err
+	}
+
+	linkHash, err := hashFile(linkPath)
+	if err != nil {
+		return false, err
+	}
+
+	if srcHash != linkHash {
+		return false, nil
+	}
+
+	if err := os.Link(linkPath, dst); err != nil {
+		log.Printf("%s: %v", ErrLinkFile, err)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func hashFile(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func(in *os.File) {
+		err := in.Close()
+		if err != nil {
+			log.Printf("%s: %v", ErrClosingFile, err)
+		}
+	}(in)
+
+	h := sha256.New()
+	if _, err := io.Copy(h, in); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 func exists(filePath string) bool {
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return false
@@ -180,13 +268,30 @@ func createIfNotExists(dir string, perm os.FileMode) error {
 }
 
 func buildDefaultSrcPath() string {
-	path := os.Getenv(ConfigAppData)
-	return fmt.Sprintf("%s\\%s\\%s", path, ConfigDefaultAppDataPath, ConfigDefaultSavePath)
+	if runtime.GOOS == "windows" {
+		path := os.Getenv(ConfigAppData)
+		return filepath.Join(path, filepath.FromSlash(ConfigDefaultAppDataPath), ConfigDefaultSavePath)
+	}
+
+	// Noita only ships for Windows, elsewhere it runs through Steam's Proton
+	return filepath.Join(homeDir(), filepath.FromSlash(ConfigDefaultProtonPath), ConfigDefaultSavePath)
 }
 
 func buildDefaultDstPath() string {
-	path := os.Getenv(ConfigUserProfile)
-	return fmt.Sprintf("%s\\%s", path, ConfigDefaultDstPath)
+	if runtime.GOOS == "windows" {
+		path := os.Getenv(ConfigUserProfile)
+		return filepath.Join(path, ConfigDefaultDstPath)
+	}
+
+	return filepath.Join(homeDir(), ConfigDefaultDstPath)
+}
+
+func homeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Printf("%s: %v", ErrHomeDir, err)
+	}
+	return home
 }
 
 func deletePath(l *LogRing, path string) error {
@@ -204,20 +309,26 @@ func LaunchExplorer() error {
 	dstPath := viper.GetString(ViperDestinationPath)
 
 	// TODO: find out why explorer always returns an error code
-	cmd := exec.Command(ExplorerExe, dstPath)
+	explorer := ExplorerExe
+	if runtime.GOOS != "windows" {
+		explorer = LinuxExplorerExe
+	}
+	cmd := exec.Command(explorer, dstPath)
 	_ = cmd.Run()
 	return nil
 }
 
-func LaunchNoita(async bool) error {
+func LaunchNoita(async bool, detector ProcessDetector) error {
 	cmd := exec.Command(viper.GetString(ViperSteamPath), SteamNoitaFlags)
 
-	if !isNoitaRunning() {
+	if process, running := detector.NoitaProcess(); !running {
 		if async {
 			err := cmd.Start()
 			if err != nil {
 				return fmt.Errorf("%s: %v", ErrRunningSteam, err)
 			}
+			// reap the launcher once it exits so it does not linger as a zombie
+			go func() { _ = cmd.Wait() }()
 		} else {
 			err := cmd.Run()
 			if err != nil {
@@ -225,8 +336,17 @@ func LaunchNoita(async bool) error {
 			}
 		}
 	} else {
-		return fmt.Errorf(ErrNoitaRunning)
+		return fmt.Errorf("%s (%s)", ErrNoitaRunning, process)
 	}
 
 	return nil
 }
+
+// samePath compares two paths the way the file system does, ignoring case on Windows.
+func samePath(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+
+	return a == b
+}
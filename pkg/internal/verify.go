@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// VerifyResult lists every problem found while comparing a backup against its manifest.
+// Unverifiable is set for a backup that recorded neither a manifest nor a file list to
+// compare against, which is not a problem in itself.
+type VerifyResult struct {
+	ID           string   `json:"id"`
+	Path         string   `json:"path"`
+	Unverifiable bool     `json:"unverifiable,omitempty"`
+	Missing      []string `json:"missing,omitempty"`
+	Extra        []string `json:"extra,omitempty"`
+	Truncated    []string `json:"truncated,omitempty"`
+	Mismatched   []string `json:"mismatched,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+func (v *VerifyResult) OK() bool {
+	return v.Error == "" && len(v.Missing) == 0 && len(v.Extra) == 0 && len(v.Truncated) == 0 && len(v.Mismatched) == 0
+}
+
+// Problems returns a human readable line for every problem found.
+func (v *VerifyResult) Problems() []string {
+	var problems []string
+	if v.Error != "" {
+		problems = append(problems, v.Error)
+	}
+	for _, p := range v.Missing {
+		problems = append(problems, fmt.Sprintf("%s: %s", InfoVerifyMissing, p))
+	}
+	for _, p := range v.Extra {
+		problems = append(problems, fmt.Sprintf("%s: %s", InfoVerifyExtra, p))
+	}
+	for _, p := range v.Truncated {
+		problems = append(problems, fmt.Sprintf("%s: %s", InfoVerifyTruncated, p))
+	}
+	for _, p := range v.Mismatched {
+		problems = append(problems, fmt.Sprintf("%s: %s", InfoVerifyMismatched, p))
+	}
+
+	return problems
+}
+
+// VerifyBackups verifies the backup with the given id, or every backup when all is set.
+func VerifyBackups(backupPath, id string, all bool, numOfWorkers int) ([]*VerifyResult, error) {
+	backupDirs, err := getBackupDirs(backupPath, TimeFormat)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrFailedGettingBackupDirs, err)
+	}
+
+	if !all {
+		timestamp, err := findBackup(backupDirs, id)
+		if err != nil {
+			return nil, err
+		}
+		backupDirs = []time.Time{timestamp}
+	}
+
+	var results []*VerifyResult
+	for _, timestamp := range backupDirs {
+		results = append(results, verifyBackup(getBackupPath(backupPath, timestamp), timestamp, numOfWorkers))
+	}
+
+	return results, nil
+}
+
+func verifyBackup(path string, timestamp time.Time, numOfWorkers int) *VerifyResult {
+	result := &VerifyResult{ID: timestamp.Format(TimeFormat), Path: path}
+
+	reader, err := openBackup(path)
+	if err != nil {
+		result.Error = fmt.Sprintf("%s: %v", ErrOpeningBackup, err)
+		return result
+	}
+	defer func() { _ = reader.Close() }()
+
+	m, err := reader.Manifest()
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && reader.format == FormatZip:
+		// backups taken before manifests were recorded still carry the zip file list
+		verifyZipEntries(reader, result, numOfWorkers)
+		return result
+	case errors.Is(err, fs.ErrNotExist):
+		result.Unverifiable = true
+		return result
+	case err != nil:
+		result.Error = fmt.Sprintf("%s: %v", ErrReadingManifest, err)
+		return result
+	}
+
+	entries, err := reader.Entries()
+	if err != nil {
+		result.Error = fmt.Sprintf("%s: %v", ErrOpeningBackup, err)
+		return result
+	}
+
+	actual := make(map[string]ManifestEntry, len(entries))
+	for _, entry := range entries {
+		actual[entry.Path] = entry
+	}
+
+	// files whose size matches still need their content hashed
+	var toHash []ManifestEntry
+	for _, expected := range m.Entries {
+		got, ok := actual[expected.Path]
+		delete(actual, expected.Path)
+
+		switch {
+		case !ok:
+			result.Missing = append(result.Missing, expected.Path)
+		case expected.IsDir():
+		case got.Size < expected.Size:
+			result.Truncated = append(result.Truncated, expected.Path)
+		case got.Size != expected.Size:
+			result.Mismatched = append(result.Mismatched, expected.Path)
+		default:
+			toHash = append(toHash, expected)
+		}
+	}
+	for path := range actual {
+		result.Extra = append(result.Extra, path)
+	}
+
+	var mu sync.Mutex
+	err = parallelFor(len(toHash), numOfWorkers, func(i int) error {
+		hash, err := hashBackupFile(reader, toHash[i].Path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ErrHashingFile, err)
+		}
+		if hash != toHash[i].SHA256 {
+			mu.Lock()
+			result.Mismatched = append(result.Mismatched, toHash[i].Path)
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	sort.Strings(result.Missing)
+	sort.Strings(result.Extra)
+	sort.Strings(result.Truncated)
+	sort.Strings(result.Mismatched)
+
+	return result
+}
+
+// verifyZipEntries checks every file of a zip backup against the size and checksum
+// recorded in the zip file list.
+func verifyZipEntries(reader *backupReader, result *VerifyResult, numOfWorkers int) {
+	var files []*zip.File
+	for _, f := range reader.zip.File {
+		if !f.FileInfo().IsDir() {
+			files = append(files, f)
+		}
+	}
+
+	var mu sync.Mutex
+	err := parallelFor(len(files), numOfWorkers, func(i int) error {
+		in, err := files[i].Open()
+		if err != nil {
+			return fmt.Errorf("%s: %v", ErrHashingFile, err)
+		}
+		defer func() { _ = in.Close() }()
+
+		n, err := io.Copy(io.Discard, in)
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case n < int64(files[i].UncompressedSize64), errors.Is(err, io.ErrUnexpectedEOF):
+			result.Truncated = append(result.Truncated, files[i].Name)
+		case err != nil:
+			result.Mismatched = append(result.Mismatched, files[i].Name)
+		}
+		return nil
+	})
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	sort.Strings(result.Truncated)
+	sort.Strings(result.Mismatched)
+}
+
+func hashBackupFile(reader *backupReader, path string) (string, error) {
+	in, err := reader.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = in.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, in); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
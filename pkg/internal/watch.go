@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Watcher backs up save00 each time Noita exits.  An exit only counts once Noita
+// stayed stopped for Debounce, so a quick restart does not trigger a backup, and
+// backups are at least MinInterval apart.
+//
+// With SaveScum set to auto or prompt a run that ended in death restores the newest
+// backup taken before it instead, prompt asks Confirm first.
+type Watcher struct {
+	PollInterval  time.Duration
+	Debounce      time.Duration
+	MinInterval   time.Duration
+	SkipUnchanged bool
+	SaveScum      string
+	Confirm       func(question string) bool
+	LogRing       *LogRing
+	detector      ProcessDetector
+	newBackup     func() *Backup
+	running       bool
+	startedAt     time.Time
+	exitedAt      time.Time
+	lastBackup    time.Time
+}
+
+func NewWatcher(detector ProcessDetector, newBackup func() *Backup, pollInterval, debounce, minInterval time.Duration, skipUnchanged bool) *Watcher {
+	return &Watcher{
+		PollInterval:  pollInterval,
+		Debounce:      debounce,
+		MinInterval:   minInterval,
+		SkipUnchanged: skipUnchanged,
+		SaveScum:      SaveScumOff,
+		LogRing:       NewLogRing(1),
+		detector:      detector,
+		newBackup:     newBackup,
+	}
+}
+
+// Run polls the Noita process state until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) error {
+	w.LogRing.LogAndAppend(fmt.Sprintf(InfoWatching, w.PollInterval, w.Debounce, w.MinInterval))
+
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	w.poll(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			w.poll(now)
+		}
+	}
+}
+
+// poll records the current process state and backs up once an exit has settled,
+// it returns true when a backup was taken.
+func (w *Watcher) poll(now time.Time) bool {
+	process, running := w.detector.NoitaProcess()
+	switch {
+	case running && !w.running:
+		w.LogRing.LogAndAppend(fmt.Sprintf(InfoNoitaStarted, process))
+		w.startedAt = now
+		w.exitedAt = time.Time{}
+	case !running && w.running:
+		w.LogRing.LogAndAppend(InfoNoitaExited)
+		w.exitedAt = now
+	}
+	w.running = running
+
+	if running || w.exitedAt.IsZero() || now.Sub(w.exitedAt) < w.Debounce {
+		return false
+	}
+	w.exitedAt = time.Time{}
+
+	if w.SaveScum == SaveScumAuto || w.SaveScum == SaveScumPrompt {
+		if w.saveScum() {
+			return false
+		}
+	}
+
+	return w.backup(now)
+}
+
+// saveScum restores the newest backup taken before the run that just ended when it
+// ended in death and returns true when it did.
+func (w *Watcher) saveScum() bool {
+	b := w.newBackup()
+	outcome, err := lastSessionOutcome(b.srcPath)
+	if err != nil {
+		w.LogRing.LogAndAppend(fmt.Sprintf("%s: %v", ErrReadingSession, err))
+		return false
+	}
+
+	// only a death in the session that was just played counts
+	if !outcome.Dead || outcome.Ended.Before(w.startedAt) {
+		return false
+	}
+	w.LogRing.LogAndAppend(fmt.Sprintf(InfoNoitaDied, outcome.KilledBy, outcome.File))
+
+	timestamp, err := preDeathBackup(b.dstPath, outcome)
+	if err != nil {
+		w.LogRing.LogAndAppend(err.Error())
+		return false
+	}
+	id := timestamp.Format(TimeFormat)
+
+	if w.SaveScum == SaveScumPrompt && (w.Confirm == nil || !w.Confirm(fmt.Sprintf(InfoSaveScumPrompt, id))) {
+		w.LogRing.LogAndAppend(InfoSaveScumDeclined)
+		return false
+	}
+
+	// the run that ended in death is the progress being thrown away
+	restore := NewRestore(id, b)
+	restore.Force = true
+	if err := restore.restoreNoita(); err != nil {
+		w.LogRing.LogAndAppend(fmt.Sprintf("%s: %v", ErrSaveScumFailed, err))
+		return false
+	}
+	if err := recordSaveScum(b.dstPath, id, outcome); err != nil {
+		w.LogRing.LogAndAppend(fmt.Sprintf("%s: %v", ErrRecordingSaveScum, err))
+	}
+
+	return true
+}
+
+func (w *Watcher) backup(now time.Time) bool {
+	if !w.lastBackup.IsZero() && now.Sub(w.lastBackup) < w.MinInterval {
+		w.LogRing.LogAndAppend(fmt.Sprintf(InfoSkipMinInterval, now.Sub(w.lastBackup).Round(time.Second), w.MinInterval))
+		return false
+	}
+
+	b := w.newBackup()
+	if w.SkipUnchanged {
+		changed, err := saveChanged(b.srcPath, b.dstPath)
+		if err != nil {
+			w.LogRing.LogAndAppend(fmt.Sprintf("%s: %v", ErrComparingSave00, err))
+		} else if !changed {
+			w.LogRing.LogAndAppend(InfoSkipUnchanged)
+			return false
+		}
+	}
+
+	// a failed backup does not hold back the next one
+	if err := b.backupNoita(); err != nil {
+		return false
+	}
+	w.lastBackup = now
+	return true
+}
+
+// saveChanged reports whether any file below srcPath differs in path, size or
+// modification time from the manifest of the newest backup in backupPath.
+func saveChanged(srcPath, backupPath string) (bool, error) {
+	backupDirs, err := getBackupDirs(backupPath, TimeFormat)
+	if err != nil {
+		return false, err
+	}
+	if len(backupDirs) == 0 {
+		return true, nil
+	}
+
+	reader, err := openBackup(getBackupPath(backupPath, backupDirs[len(backupDirs)-1]))
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	m, err := reader.Manifest()
+	if err != nil {
+		// backups taken before manifests existed cannot be compared against
+		return true, nil
+	}
+
+	entries, err := walkManifestEntries(srcPath)
+	if err != nil {
+		return false, err
+	}
+
+	backedUp := make(map[string]ManifestEntry)
+	for _, entry := range m.Entries {
+		if !entry.IsDir() {
+			backedUp[entry.Path] = entry
+		}
+	}
+
+	files := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		files++
+		previous, ok := backedUp[entry.Path]
+		if !ok || previous.Size != entry.Size || !previous.ModTime.Equal(entry.ModTime) {
+			return true, nil
+		}
+	}
+
+	return files != len(backedUp), nil
+}
@@ -8,38 +8,74 @@ import (
 )
 
 type Job struct {
-	src string
-	dst string
+	src  string
+	dst  string
+	link string
 }
 
 func worker(jobs chan Job, errs chan error, workersGroup *sync.WaitGroup) {
 	defer workersGroup.Done()
 
 	for job := range jobs {
-		srcInfo, err := os.Stat(job.src)
-		if err != nil {
-			errs <- fmt.Errorf("%s: %w", ErrStatFile, err)
+		if err := copyJob(job); err != nil {
+			errs <- err
+			// drain the remaining jobs so the directory walk never blocks
+			for range jobs {
+			}
 			return
 		}
-		switch srcInfo.Mode() & os.ModeType {
-		case os.ModeDir:
-			continue
-		default:
-			if err := copyFile(job.src, job.dst); err != nil {
-				errs <- fmt.Errorf("%s: %v", ErrCopyFile, err)
-				return
+	}
+}
+
+func copyJob(job Job) error {
+	srcInfo, err := os.Stat(job.src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrStatFile, err)
+	}
+	switch srcInfo.Mode() & os.ModeType {
+	case os.ModeDir:
+		return nil
+	default:
+		if job.link != "" {
+			linked, err := linkIfIdentical(job.src, job.link, job.dst)
+			if err != nil {
+				return fmt.Errorf("%s: %v", ErrLinkFile, err)
 			}
+			if linked {
+				return nil
+			}
+		}
+		if err := copyFile(job.src, job.dst); err != nil {
+			return fmt.Errorf("%s: %v", ErrCopyFile, err)
+		}
+		// keep the source modification time so later backups can compare against it
+		if err := os.Chtimes(job.dst, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
+			return fmt.Errorf("%s: %v", ErrCopyFile, err)
 		}
 	}
+
+	return nil
 }
 
-func concurrentCopy(src, dst string, dirCounter, fileCounter *int, numOfWorkers int) error {
-	jobs := make(chan Job)
-	errs := make(chan error)
+// concurrentCopy recursively copies src to dst, leaving out the top level entry named
+// exclude.  When linkDest is set, files that are unchanged compared to the same relative
+// path below linkDest are hardlinked instead.
+func concurrentCopy(src, dst, linkDest, exclude string, dirCounter, fileCounter *int, numOfWorkers int) error {
+	if numOfWorkers <= 0 {
+		numOfWorkers = 1
+	}
 
-	go hydrateChannel(jobs, errs, src, dst, dirCounter, fileCounter)
+	// every worker and the directory walk report at most one error each
+	jobs := make(chan Job)
+	errs := make(chan error, numOfWorkers+1)
 
 	var workersGroup sync.WaitGroup
+	workersGroup.Add(1)
+	go func() {
+		defer workersGroup.Done()
+		hydrateChannel(jobs, errs, src, dst, linkDest, exclude, dirCounter, fileCounter)
+	}()
+
 	for i := 0; i < numOfWorkers; i++ {
 		workersGroup.Add(1)
 		go worker(jobs, errs, &workersGroup)
@@ -58,10 +94,51 @@ func concurrentCopy(src, dst string, dirCounter, fileCounter *int, numOfWorkers
 	return nil
 }
 
-func hydrateChannel(jobs chan Job, errs chan error, src, dst string, dirCounter, fileCounter *int) {
+func hydrateChannel(jobs chan Job, errs chan error, src, dst, linkDest, exclude string, dirCounter, fileCounter *int) {
 	defer close(jobs)
-	if err := buildDirectory(jobs, src, dst, dirCounter, fileCounter); err != nil {
+	if err := buildDirectory(jobs, src, dst, linkDest, exclude, dirCounter, fileCounter); err != nil {
 		errs <- err
 		return
 	}
 }
+
+// parallelFor calls fn for every index below total using numOfWorkers go routines
+// and returns all errors that occurred joined together.
+func parallelFor(total, numOfWorkers int, fn func(i int) error) error {
+	indexes := make(chan int)
+	errs := make(chan error, total)
+
+	if numOfWorkers <= 0 {
+		numOfWorkers = 1
+	}
+
+	var workersGroup sync.WaitGroup
+	for i := 0; i < numOfWorkers; i++ {
+		workersGroup.Add(1)
+		go func() {
+			defer workersGroup.Done()
+			for index := range indexes {
+				if err := fn(index); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < total; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	workersGroup.Wait()
+
+	close(errs)
+	errors := make([]string, 0)
+	for err := range errs {
+		errors = append(errors, err.Error())
+	}
+	if len(errors) > 0 {
+		return fmt.Errorf("%d worker errors occurred: %s", len(errors), strings.Join(errors, ","))
+	}
+
+	return nil
+}